@@ -0,0 +1,58 @@
+package van
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+type closersKey struct{}
+
+// closers collects the auto-closed dependencies constructed during a single call.
+// All methods are safe to call on a nil receiver, which is used when there are no
+// auto-closed providers registered.
+type closers struct {
+	mu   sync.Mutex
+	list []io.Closer
+}
+
+func withClosers(ctx context.Context, c *closers) context.Context {
+	return context.WithValue(ctx, closersKey{}, c)
+}
+
+func closersFromContext(ctx context.Context) *closers {
+	c, _ := ctx.Value(closersKey{}).(*closers)
+	return c
+}
+
+func (c *closers) add(closer io.Closer) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.list = append(c.list, closer)
+	c.mu.Unlock()
+}
+
+// close closes the collected dependencies in reverse order of construction and returns the first error.
+func (c *closers) close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+
+	for i := len(c.list) - 1; i >= 0; i-- {
+		if err := c.list[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	c.list = nil
+
+	return firstErr
+}
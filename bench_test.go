@@ -212,6 +212,26 @@ func BenchmarkInvoke_SingleDependency(b *testing.B) {
 	}
 }
 
+func BenchmarkInvoke_NoDependencies(b *testing.B) {
+	ctx := context.Background()
+	bus := New()
+
+	bus.Handle(benchCommand{}, func(ctx context.Context, cmd *benchCommand) error {
+		return nil
+	})
+
+	var err error
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err = bus.Invoke(ctx, &benchCommand{val: i})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkPublish_LageGraphTransitive(b *testing.B) {
 	bus := New()
 
@@ -409,3 +429,63 @@ func BenchmarkFuncCall_Reflection(b *testing.B) {
 		divfn.Call(args)
 	}
 }
+
+func BenchmarkInvoke_Reflection2Deps(b *testing.B) {
+	bus := New()
+	ctx := context.Background()
+	cmd := &benchCommand{val: 1}
+
+	bus.ProvideOnce(func() (serviceA, error) {
+		return &serviceImpl{ret: 1}, nil
+	})
+	bus.ProvideOnce(func() (serviceB, error) {
+		return &serviceImpl{ret: 2}, nil
+	})
+
+	bus.Handle(benchCommand{}, func(ctx context.Context, cmd *benchCommand, a serviceA, b serviceB) error {
+		a.Run()
+		b.Run()
+		return nil
+	})
+
+	var err error
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err = bus.Invoke(ctx, cmd)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInvoke_Typed2Deps(b *testing.B) {
+	bus := New()
+	ctx := context.Background()
+	cmd := &benchCommand{val: 1}
+
+	bus.ProvideOnce(func() (serviceA, error) {
+		return &serviceImpl{ret: 1}, nil
+	})
+	bus.ProvideOnce(func() (serviceB, error) {
+		return &serviceImpl{ret: 2}, nil
+	})
+
+	HandleTyped2(bus, func(ctx context.Context, cmd *benchCommand, a serviceA, b serviceB) error {
+		a.Run()
+		b.Run()
+		return nil
+	})
+
+	var err error
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err = bus.Invoke(ctx, cmd)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package van
+
+// Clone returns a new bus with the same options, providers, handlers and listeners, which can then be adjusted
+// with Override and the other registration methods without affecting the original, e.g. to swap a few providers
+// in a test or per tenant. The provider functions are shared, but the singleton instances are not: each clone
+// constructs its own singletons, and the singletons constructed later by the original are not seen by the clone.
+// The idempotency state of the handlers and the events seen by WithDedup are not shared either. Recording and
+// the pending cleanups are not copied.
+func (b *Van) Clone() *Van {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	c := New()
+
+	c.now = b.now
+	c.resolveTimeout = b.resolveTimeout
+	c.slowResolveThreshold = b.slowResolveThreshold
+	c.deferValidation = b.deferValidation
+	c.recoverPanics = b.recoverPanics
+	c.panicHandler = b.panicHandler
+	c.unhandledCommand = b.unhandledCommand
+	c.strictBus = b.strictBus
+	c.deadLetter = b.deadLetter
+	c.interceptor = b.interceptor
+	c.middleware = append([]Middleware(nil), b.middleware...)
+	c.hooks = b.hooks
+	c.tracer = b.tracer
+	c.logger = b.logger
+
+	c.hasAutoClose.Store(b.hasAutoClose.Load())
+	c.hasScoped.Store(b.hasScoped.Load())
+
+	if b.dedupSeen != nil {
+		c.dedupKey = b.dedupKey
+		c.dedupSeen = newTTLSet(b.dedupSeen.ttl)
+	}
+
+	if b.resolveSlots != nil {
+		c.resolveSlots = make(chan struct{}, cap(b.resolveSlots))
+	}
+
+	if b.listenerSlots != nil {
+		c.listenerSlots = make(chan struct{}, cap(b.listenerSlots))
+	}
+
+	for k, p := range b.providers {
+		c.providers[k] = p.clone(false)
+	}
+
+	for t, group := range b.groups {
+		for _, p := range group {
+			c.groups[t] = append(c.groups[t], p.clone(false))
+		}
+	}
+
+	for t, h := range b.handlers {
+		h = h.clone()
+
+		if h.idempotency != nil {
+			h.idempotency = h.idempotency.clone()
+		}
+
+		c.addHandler(t, h, nil)
+	}
+
+	// the listener and decorator slices are never modified in place, so they can be shared
+	for t, listeners := range b.listeners {
+		c.listeners[t] = listeners
+	}
+
+	for t, decorators := range b.decorators {
+		c.decorators[t] = decorators
+	}
+
+	return c
+}
+
+// clone returns a copy of the idempotency settings with an empty seen-set.
+func (i *idempotency) clone() *idempotency {
+	return &idempotency{
+		key:    i.key,
+		verify: i.verify,
+		seen:   newTTLSet(i.seen.ttl),
+	}
+}
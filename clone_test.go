@@ -0,0 +1,75 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	var constructed int
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		constructed++
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		cmd.Result = get.Get()
+		return nil
+	})
+
+	if _, err := Resolve[GetIntService](context.Background(), bus); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := bus.Clone()
+
+	if _, err := Resolve[GetIntService](context.Background(), clone); err != nil {
+		t.Fatal(err)
+	}
+
+	if constructed != 2 {
+		t.Fatal("expected the clone to construct its own singleton")
+	}
+
+	clone.Override(func() (GetIntService, error) {
+		return constIntService(2), nil
+	})
+
+	for bus, want := range map[*Van]int{bus: 1, clone: 2} {
+		cmd := &Command{}
+		if err := bus.Invoke(context.Background(), cmd); err != nil {
+			t.Fatal(err)
+		}
+
+		if cmd.Result != want {
+			t.Fatalf("expected %d, got %d", want, cmd.Result)
+		}
+	}
+}
+
+func TestClone_Listeners(t *testing.T) {
+	var calls []string
+
+	bus := New()
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		calls = append(calls, "original")
+	})
+
+	clone := bus.Clone()
+
+	clone.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		calls = append(calls, "clone")
+	})
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 1 || calls[0] != "original" {
+		t.Fatalf("expected the listeners of the clone not to affect the original, got %v", calls)
+	}
+}
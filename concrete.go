@@ -0,0 +1,148 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// addConcrete makes the singleton instance injectable by its concrete type, e.g. *InMemoryCounter for
+// the instance of Counter, as long as it is a struct pointer. A concrete type shared by the instances of
+// several singletons is ambiguous, and is marked with a nil instance. It must be called with the lock held.
+func (b *Van) addConcrete(instance interface{}) {
+	t := reflect.TypeOf(instance)
+	if t == nil || !isStructPtr(t) {
+		return
+	}
+
+	if existing, ok := b.concrete[t]; ok && existing != instance {
+		b.concrete[t] = nil
+		return
+	}
+
+	b.concrete[t] = instance
+}
+
+// removeConcrete drops the concrete type of the singleton instance of the provider, which has been replaced or
+// removed. A type that was shared with the instances of other singletons is added back for the remaining ones.
+// It must be called with the lock held, after the provider is unregistered.
+func (b *Van) removeConcrete(p *providerOpts) {
+	p.RLock()
+	instance := p.instance
+	p.RUnlock()
+
+	t := reflect.TypeOf(instance)
+	if t == nil {
+		return
+	}
+
+	if existing, ok := b.concrete[t]; !ok || (existing != nil && existing != instance) {
+		return
+	}
+
+	delete(b.concrete, t)
+
+	readd := func(other *providerOpts) {
+		other.RLock()
+		defer other.RUnlock()
+
+		if other.instance != nil && reflect.TypeOf(other.instance) == t {
+			b.addConcrete(other.instance)
+		}
+	}
+
+	for _, other := range b.providers {
+		readd(other)
+	}
+
+	for _, group := range b.groups {
+		for _, other := range group {
+			readd(other)
+		}
+	}
+}
+
+// concreteCandidate is a singleton provider whose instance may be of a given concrete type.
+type concreteCandidate struct {
+	key      providerKey
+	provider *providerOpts
+}
+
+// concreteCandidates returns the singleton providers of the interfaces implemented by the struct pointer type,
+// which may construct an instance of that type, sorted by their keys. It must be called with the lock held.
+func (b *Van) concreteCandidates(t reflect.Type) []concreteCandidate {
+	if !isStructPtr(t) {
+		return nil
+	}
+
+	var candidates []concreteCandidate
+
+	add := func(key providerKey, p *providerOpts) {
+		if p.singleton && key.typ.Kind() == reflect.Interface && t.Implements(key.typ) {
+			candidates = append(candidates, concreteCandidate{key: key, provider: p})
+		}
+	}
+
+	for key, p := range b.providers {
+		add(key, p)
+	}
+
+	for typ, group := range b.groups {
+		for _, p := range group {
+			add(providerKey{typ: typ}, p)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].key.String() < candidates[j].key.String()
+	})
+
+	return candidates
+}
+
+// isConcreteType reports whether the dependencies on the type can be satisfied by a singleton instance, either
+// already constructed or to be constructed by one of the candidates, so that the outcome of the validation does
+// not depend on whether the singletons have been constructed yet. It must be called with the lock held.
+func (b *Van) isConcreteType(t reflect.Type) bool {
+	if _, ok := b.concrete[t]; ok {
+		return true
+	}
+
+	return len(b.concreteCandidates(t)) > 0
+}
+
+// concreteInstance returns the singleton instance of the concrete type. The singletons that may be of the type
+// are constructed first if there is no such instance yet.
+func (b *Van) concreteInstance(ctx context.Context, t reflect.Type) (reflect.Value, bool, error) {
+	b.mu.RLock()
+	instance, ok := b.concrete[t]
+
+	var candidates []concreteCandidate
+	if !ok {
+		candidates = b.concreteCandidates(t)
+	}
+	b.mu.RUnlock()
+
+	if !ok && len(candidates) > 0 {
+		for _, c := range candidates {
+			if _, err := b.instantiate(ctx, c.key.typ, c.provider); err != nil {
+				return reflect.Value{}, true, err
+			}
+		}
+
+		b.mu.RLock()
+		instance, ok = b.concrete[t]
+		b.mu.RUnlock()
+	}
+
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+
+	if instance == nil {
+		return reflect.Value{}, true, fmt.Errorf("%s is the concrete type of several singletons", t.String())
+	}
+
+	return reflect.ValueOf(instance), true, nil
+}
@@ -0,0 +1,174 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConcreteSingleton(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return &getSetIntService{value: 42}, nil
+	})
+
+	if err := bus.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Provide(func(s *getSetIntService) (SetIntService, error) {
+		return s, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService, set SetIntService, impl *getSetIntService) error {
+		if impl != get || impl != set {
+			t.Error("expected the same instance to be injected by its concrete type")
+		}
+
+		cmd.Result = impl.value
+
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 42 {
+		t.Fatalf("expected result 42, got %d", cmd.Result)
+	}
+
+	if err := bus.ValidateGraph(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcreteSingleton_NotConstructed(t *testing.T) {
+	var calls int
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		calls++
+		return &getSetIntService{value: 42}, nil
+	})
+
+	// accepted before the singleton is constructed, which then happens on first use
+	bus.Provide(func(s *getSetIntService) (SetIntService, error) {
+		return s, nil
+	})
+
+	if err := bus.ValidateGraph(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := bus.Exec(context.Background(), func(set SetIntService, get GetIntService) error {
+		if set.(*getSetIntService) != get.(*getSetIntService) {
+			t.Error("expected the same instance to be injected by its concrete type")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected the singleton to be constructed once, got %d", calls)
+	}
+}
+
+func TestConcreteSingleton_NoCandidates(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &getSetIntService{}, nil
+	})
+
+	panicsWithError(t, "no providers registered for type *van.getSetIntService", func() {
+		bus.Provide(func(s *getSetIntService) (SetIntService, error) {
+			return s, nil
+		})
+	})
+}
+
+func TestConcreteSingleton_Ambiguous(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return &getSetIntService{}, nil
+	})
+
+	bus.ProvideOnce(func() (SetIntService, error) {
+		return &getSetIntService{}, nil
+	})
+
+	if err := bus.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Resolve[*getSetIntService](context.Background(), bus)
+	if err == nil || err.Error() != "*van.getSetIntService is the concrete type of several singletons" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestConcreteSingleton_Override(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return &getSetIntService{value: 1}, nil
+	})
+
+	if err := bus.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Override(func() (GetIntService, error) {
+		return &getSetIntService{value: 2}, nil
+	})
+
+	impl, err := Resolve[*getSetIntService](context.Background(), bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if impl.value != 2 {
+		t.Fatalf("expected the instance of the new provider, got %d", impl.value)
+	}
+}
+
+func TestConcreteSingleton_Remove(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return &getSetIntService{value: 1}, nil
+	})
+
+	bus.ProvideOnce(func() (SetIntService, error) {
+		return &getSetIntService{value: 2}, nil
+	})
+
+	if err := bus.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.RemoveProvider((*GetIntService)(nil))
+
+	// the type is no longer ambiguous once one of the instances is gone
+	impl, err := Resolve[*getSetIntService](context.Background(), bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if impl.value != 2 {
+		t.Fatalf("expected the instance of the remaining provider, got %d", impl.value)
+	}
+
+	bus.RemoveProvider((*SetIntService)(nil))
+
+	if _, err := Resolve[*getSetIntService](context.Background(), bus); err == nil {
+		t.Fatal("expected the removed instance to be dropped")
+	}
+}
@@ -0,0 +1,85 @@
+package van
+
+import (
+	"strings"
+	"sync"
+)
+
+// waitGraph tracks which singletons the constructions in progress are waiting for, across all goroutines,
+// so that a circular dependency split between the goroutines, e.g. by WithParallelResolve, is reported
+// instead of deadlocking on the construction semaphores. The context chain only catches the cycles
+// within a single goroutine.
+type waitGraph struct {
+	mu sync.Mutex
+
+	// edges holds the constructions each provider is waiting for, which is an edge from the provider
+	// to the provider of the construction.
+	edges map[*providerOpts][]*constructing
+}
+
+// wait records that the construction of the parent waits for the node, unless the node is already waiting
+// for the parent, directly or transitively, in which case the path of the cycle is returned.
+func (g *waitGraph) wait(parent, node *constructing) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if path, ok := g.path(node.provider, parent.provider, map[*providerOpts]bool{}); ok {
+		types := []string{parent.typ.String(), node.typ.String()}
+		for _, link := range path {
+			types = append(types, link.typ.String())
+		}
+
+		return strings.Join(types, " -> "), true
+	}
+
+	if g.edges == nil {
+		g.edges = make(map[*providerOpts][]*constructing)
+	}
+
+	g.edges[parent.provider] = append(g.edges[parent.provider], node)
+
+	return "", false
+}
+
+// done removes the edge added by wait once the construction of the node is complete or abandoned.
+func (g *waitGraph) done(parent, node *constructing) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges := g.edges[parent.provider]
+
+	for i, link := range edges {
+		if link == node {
+			edges = append(edges[:i:i], edges[i+1:]...)
+			break
+		}
+	}
+
+	if len(edges) == 0 {
+		delete(g.edges, parent.provider)
+		return
+	}
+
+	g.edges[parent.provider] = edges
+}
+
+// path returns the constructions leading from one provider to another, if there is a path between them.
+func (g *waitGraph) path(from, to *providerOpts, visited map[*providerOpts]bool) ([]*constructing, bool) {
+	if visited[from] {
+		return nil, false
+	}
+
+	visited[from] = true
+
+	for _, link := range g.edges[from] {
+		if link.provider == to {
+			return []*constructing{link}, true
+		}
+
+		if rest, ok := g.path(link.provider, to, visited); ok {
+			return append([]*constructing{link}, rest...), true
+		}
+	}
+
+	return nil, false
+}
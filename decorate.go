@@ -0,0 +1,57 @@
+package van
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Decorator wraps an instance constructed by a provider, e.g. with a logging or caching proxy. It receives
+// the instance and returns the one to inject instead, which must implement the same interface.
+type Decorator func(next interface{}) interface{}
+
+// Decorate registers the decorator for the interface type, which is passed as a nil pointer to the interface,
+// e.g. (*UserRepo)(nil). The instances built by the providers of the type, including the named and group ones,
+// are passed through its decorators in the order of registration before they are injected. Singletons are
+// decorated once, when they are constructed, so the decorators registered afterwards do not affect them.
+// It panics if the argument is not a pointer to an interface.
+func (b *Van) Decorate(ifacePtr interface{}, decorator Decorator) {
+	ifaceType, err := interfaceOf(ifacePtr)
+	if err != nil {
+		panic(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the slice is copied rather than appended in place, since it might be iterated by decorate
+	current := b.decorators[ifaceType]
+
+	updated := make([]Decorator, 0, len(current)+1)
+	updated = append(updated, current...)
+	updated = append(updated, decorator)
+
+	b.decorators[ifaceType] = updated
+}
+
+// decorate passes the instance of type t through the decorators of the type.
+func (b *Van) decorate(t reflect.Type, inst reflect.Value) (reflect.Value, error) {
+	b.mu.RLock()
+	decorators := b.decorators[t]
+	b.mu.RUnlock()
+
+	if len(decorators) == 0 {
+		return inst, nil
+	}
+
+	value := inst.Interface()
+
+	for _, decorator := range decorators {
+		value = decorator(value)
+
+		if value == nil || !reflect.TypeOf(value).AssignableTo(t) {
+			return reflect.ValueOf(nil), fmt.Errorf("decorator of %s returned %T, which is not assignable to it", t.String(), value)
+		}
+	}
+
+	return reflect.ValueOf(value).Convert(t), nil
+}
@@ -0,0 +1,75 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+type doubleIntService struct {
+	next GetIntService
+}
+
+func (s *doubleIntService) Get() int {
+	return s.next.Get() * 2
+}
+
+func TestDecorate(t *testing.T) {
+	var constructed int
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		constructed++
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Decorate((*GetIntService)(nil), func(next interface{}) interface{} {
+		return &doubleIntService{next: next.(GetIntService)}
+	})
+
+	bus.Decorate((*GetIntService)(nil), func(next interface{}) interface{} {
+		return constIntService(next.(GetIntService).Get() + 1)
+	})
+
+	for i := 0; i < 2; i++ {
+		svc, err := Resolve[GetIntService](context.Background(), bus)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if svc.Get() != 3 {
+			t.Fatalf("expected the decorators to be applied in order, got %d", svc.Get())
+		}
+	}
+
+	if constructed != 1 {
+		t.Fatalf("expected the singleton to be constructed once, got %d", constructed)
+	}
+}
+
+func TestDecorate_NotAssignable(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Decorate((*GetIntService)(nil), func(next interface{}) interface{} {
+		return 1
+	})
+
+	_, err := Resolve[GetIntService](context.Background(), bus)
+
+	wantErr := "decorator of van.GetIntService returned int, which is not assignable to it"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestDecorate_NotInterface(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "expected a pointer to an interface, got int", func() {
+		bus.Decorate(1, func(next interface{}) interface{} { return next })
+	})
+}
@@ -0,0 +1,168 @@
+package van
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportDOT writes the dependency graph of the registered providers, handlers and listeners to w in the
+// Graphviz DOT format. Providers are drawn as boxes: bold for singletons, dashed for scoped providers and
+// solid for transient ones. Commands and events are drawn as arrows and hexagons pointing at their handlers
+// and listeners, which in turn point at their dependencies. The handlers of a pipeline are numbered, and
+// optional dependencies are drawn as dashed edges. The output is sorted, so the same set of registrations
+// always produces the same graph.
+func (b *Van) ExportDOT(w io.Writer) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	g := &dotGraph{}
+
+	for k, p := range b.providers {
+		id := k.String()
+		g.node(id, id, providerStyle(p))
+		g.dependencies(id, reflect.TypeOf(p.fn), 0)
+	}
+
+	for t, group := range b.groups {
+		groupID := reflect.SliceOf(t).String()
+		g.node(groupID, groupID, "shape=box3d")
+
+		for i, p := range group {
+			id := fmt.Sprintf("%s #%d", t.String(), i+1)
+			g.node(id, t.String(), providerStyle(p))
+			g.edge(groupID, id, "")
+			g.dependencies(id, reflect.TypeOf(p.fn), 0)
+		}
+	}
+
+	for t, h := range b.handlers {
+		cmdID := "command " + t.String()
+		g.node(cmdID, t.String(), "shape=cds")
+
+		if h.pipeline == nil {
+			handlerID := "handler of " + t.String()
+			g.node(handlerID, "handler", "shape=ellipse")
+			g.edge(cmdID, handlerID, "")
+			g.dependencies(handlerID, reflect.TypeOf(h.fn), handlerDepsStart(reflect.TypeOf(h.fn), t))
+
+			continue
+		}
+
+		for i, fn := range h.pipeline {
+			handlerID := fmt.Sprintf("handler #%d of %s", i+1, t.String())
+			g.node(handlerID, fmt.Sprintf("handler #%d", i+1), "shape=ellipse")
+			g.edge(cmdID, handlerID, "")
+			g.dependencies(handlerID, reflect.TypeOf(fn), handlerDepsStart(reflect.TypeOf(fn), t))
+		}
+	}
+
+	for t, listeners := range b.listeners {
+		if len(listeners) == 0 {
+			continue
+		}
+
+		eventID := "event " + t.String()
+		g.node(eventID, t.String(), "shape=hexagon")
+
+		for i, l := range listeners {
+			listenerID := fmt.Sprintf("listener #%d of %s", i+1, t.String())
+			g.node(listenerID, fmt.Sprintf("listener #%d", i+1), "shape=ellipse")
+			g.edge(eventID, listenerID, "")
+			g.dependencies(listenerID, reflect.TypeOf(l.fn), messageIndex(reflect.TypeOf(l.fn))+1)
+		}
+	}
+
+	_, err := io.WriteString(w, g.String())
+
+	return err
+}
+
+func providerStyle(p *providerOpts) string {
+	switch {
+	case p.singleton:
+		return "shape=box, style=bold"
+	case p.scoped:
+		return "shape=box, style=dashed"
+	default:
+		return "shape=box"
+	}
+}
+
+type dotGraph struct {
+	nodes []string
+	edges []string
+}
+
+func (g *dotGraph) node(id, label, attrs string) {
+	g.nodes = append(g.nodes, fmt.Sprintf("%s [label=%s, %s];", strconv.Quote(id), strconv.Quote(label), attrs))
+}
+
+func (g *dotGraph) edge(from, to, attrs string) {
+	line := strconv.Quote(from) + " -> " + strconv.Quote(to)
+	if attrs != "" {
+		line += " [" + attrs + "]"
+	}
+
+	g.edges = append(g.edges, line+";")
+}
+
+// dependencies adds the edges to the dependencies of the function, starting from the argument at the given index.
+func (g *dotGraph) dependencies(from string, fnType reflect.Type, start int) {
+	for i := start; i < fnType.NumIn(); i++ {
+		g.dependency(from, fnType.In(i), "", false)
+	}
+}
+
+func (g *dotGraph) dependency(from string, t reflect.Type, name string, optional bool) {
+	if depType, ok := optionalType(t); ok {
+		g.dependency(from, depType, "", true)
+		return
+	}
+
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			fieldName, fieldOptional := parseTag(field.Tag)
+			g.dependency(from, field.Type, fieldName, fieldOptional)
+		}
+
+		return
+	}
+
+	if name == "" && (t == typeVan || t == typePublisher || t == typeDispatcher || t == typeScope || t == typeContext) {
+		return
+	}
+
+	attrs := ""
+	if optional {
+		attrs = "style=dashed"
+	}
+
+	g.edge(from, providerKey{typ: t, name: name}.String(), attrs)
+}
+
+func (g *dotGraph) String() string {
+	sort.Strings(g.nodes)
+	sort.Strings(g.edges)
+
+	var sb strings.Builder
+
+	sb.WriteString("digraph van {\n")
+
+	for _, line := range g.nodes {
+		sb.WriteString("\t" + line + "\n")
+	}
+
+	for _, line := range g.edges {
+		sb.WriteString("\t" + line + "\n")
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package van
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestExportDOT(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Provide(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideGroup(func(ctx context.Context) (UnknownService, error) {
+		return struct{}{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		return nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, s Optional[SetIntService], u []UnknownService) {})
+
+	var buf bytes.Buffer
+
+	if err := bus.ExportDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `digraph van {
+	"[]van.UnknownService" [label="[]van.UnknownService", shape=box3d];
+	"command van.Command" [label="van.Command", shape=cds];
+	"event van.Event" [label="van.Event", shape=hexagon];
+	"handler of van.Command" [label="handler", shape=ellipse];
+	"listener #1 of van.Event" [label="listener #1", shape=ellipse];
+	"van.GetIntService" [label="van.GetIntService", shape=box];
+	"van.SetIntService" [label="van.SetIntService", shape=box, style=bold];
+	"van.UnknownService #1" [label="van.UnknownService", shape=box];
+	"[]van.UnknownService" -> "van.UnknownService #1";
+	"command van.Command" -> "handler of van.Command";
+	"event van.Event" -> "listener #1 of van.Event";
+	"handler of van.Command" -> "van.GetIntService";
+	"listener #1 of van.Event" -> "[]van.UnknownService";
+	"listener #1 of van.Event" -> "van.SetIntService" [style=dashed];
+	"van.GetIntService" -> "van.SetIntService";
+}
+`
+
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
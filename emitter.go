@@ -0,0 +1,48 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Publisher is an injectable that publishes events of any type. It is provided by the bus itself,
+// so depending on Publisher instead of *Van lets the components publish events without being
+// coupled to the rest of the bus, and makes them easy to test with a fake implementation.
+type Publisher interface {
+	Publish(event interface{}) error
+}
+
+// Dispatcher is an injectable that invokes commands of any type. It is provided by the bus itself,
+// so that handlers can compose sub-commands without depending on *Van and its registration methods.
+type Dispatcher interface {
+	Invoke(ctx context.Context, cmd interface{}) error
+}
+
+// Emitter is an injectable that publishes events of a single type E. Depending on Emitter
+// instead of *Van restricts a component to the events it is supposed to emit.
+// The emitter for the event type must be registered with ProvideEmitter.
+type Emitter[E any] interface {
+	Emit(event E) error
+}
+
+type emitter[E any] struct {
+	publisher Publisher
+}
+
+func (e *emitter[E]) Emit(event E) error {
+	return e.publisher.Publish(event)
+}
+
+// ProvideEmitter registers a provider for Emitter[E], making it available as a dependency.
+// It panics if E is not a struct, since only structs can be published as events.
+func ProvideEmitter[E any](b *Van) {
+	eventType := reflect.TypeOf((*E)(nil)).Elem()
+	if eventType.Kind() != reflect.Struct {
+		panic(fmt.Errorf("event must be a struct, got %s", eventType.String()))
+	}
+
+	b.ProvideOnce(func(publisher Publisher) (Emitter[E], error) {
+		return &emitter[E]{publisher: publisher}, nil
+	})
+}
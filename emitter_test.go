@@ -0,0 +1,125 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProvideEmitter(t *testing.T) {
+	var received []Event
+
+	bus := New()
+	ProvideEmitter[Event](bus)
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		received = append(received, event)
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, emitter Emitter[Event]) error {
+		return emitter.Emit(Event{Value: 42})
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if len(received) != 1 || received[0].Value != 42 {
+		t.Fatalf("expected a single event with value 42, got %v", received)
+	}
+}
+
+func TestProvideEmitterFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "event must be a struct, got *van.Event", func() {
+		ProvideEmitter[*Event](bus)
+	})
+}
+
+func TestPublisher(t *testing.T) {
+	var received []Event
+
+	bus := New(WithStrictBus())
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		received = append(received, event)
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, publisher Publisher) error {
+		return publisher.Publish(Event{Value: 42})
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if len(received) != 1 || received[0].Value != 42 {
+		t.Fatalf("expected a single event with value 42, got %v", received)
+	}
+
+	publisher, err := Resolve[Publisher](context.Background(), bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if publisher != Publisher(bus) {
+		t.Fatal("expected the bus to be resolved as the publisher")
+	}
+}
+
+func TestPublisherFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "provider cannot return van.Publisher, which is provided by the bus", func() {
+		bus.Provide(func() (Publisher, error) {
+			return nil, nil
+		})
+	})
+}
+
+func TestDispatcher(t *testing.T) {
+	type SubCommand struct {
+		Value int
+	}
+
+	bus := New(WithStrictBus())
+
+	bus.Handle(SubCommand{}, func(ctx context.Context, cmd *SubCommand) error {
+		cmd.Value = 42
+		return nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, dispatcher Dispatcher) error {
+		sub := &SubCommand{}
+		if err := dispatcher.Invoke(ctx, sub); err != nil {
+			return err
+		}
+
+		cmd.Result = sub.Value
+
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 42 {
+		t.Fatalf("expected result 42, got %d", cmd.Result)
+	}
+}
+
+func TestDispatcherFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "provider cannot return van.Dispatcher, which is provided by the bus", func() {
+		bus.Provide(func() (Dispatcher, error) {
+			return nil, nil
+		})
+	})
+}
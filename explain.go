@@ -0,0 +1,131 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// ResolvedDep describes a dependency injected into a command handler, as reported by Explain.
+type ResolvedDep struct {
+	// Arg is the index of the handler argument the dependency is passed in.
+	Arg int
+	// Field is the path to the field of the dependency struct, e.g. Repos.Users,
+	// or empty if the argument is not a dependency struct.
+	Field string
+	// Type is the type of the requested dependency.
+	Type reflect.Type
+	// Concrete is the dynamic type of the injected instance, e.g. *postgres.UserRepo,
+	// or nil if there is no instance, e.g. for a missing optional dependency.
+	Concrete reflect.Type
+}
+
+// Explain resolves the dependencies of the handler of the command, same as Invoke, but instead of calling
+// the handler, it reports the concrete types of the injected instances, e.g. to find out which implementation
+// a misbehaving handler receives. The providers are called, so the singletons get constructed, and the
+// auto-closed instances are closed right away. The dependencies of all handlers of a pipeline are listed
+// in order. The command may be passed either as a struct or as a pointer to it. It is a diagnostic tool,
+// which is not meant to be called on the hot path.
+func (b *Van) Explain(cmd interface{}) ([]ResolvedDep, error) {
+	if cmd == nil {
+		return nil, fmt.Errorf("cmd must be a struct, got nil")
+	}
+
+	value := reflect.ValueOf(cmd)
+	if value.Kind() != reflect.Ptr {
+		ptr := reflect.New(reflect.TypeOf(cmd))
+		ptr.Elem().Set(value)
+		value = ptr
+	}
+
+	cmdType := value.Type().Elem()
+	if cmdType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cmd must be a struct, got %s", cmdType.String())
+	}
+
+	b.mu.RLock()
+	h, ok := b.handlers[cmdType]
+	b.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w for type %s", ErrHandlerNotFound, cmdType.String())
+	}
+
+	ctx := context.Background()
+
+	var cl *closers
+
+	if b.hasAutoClose.Load() {
+		cl = &closers{}
+		ctx = withClosers(ctx, cl)
+	}
+
+	if b.hasScoped.Load() {
+		ctx = withScopedCache(ctx, &scopedCache{})
+	}
+
+	deps, err := b.explain(ctx, h, value.Interface(), cmdType)
+
+	if closeErr := cl.close(); err == nil {
+		err = closeErr
+	}
+
+	return deps, err
+}
+
+func (b *Van) explain(ctx context.Context, h *handlerOpts, cmd interface{}, cmdType reflect.Type) ([]ResolvedDep, error) {
+	var deps []ResolvedDep
+
+	for _, fn := range h.funcs() {
+		fnType := reflect.TypeOf(fn)
+
+		fnCtx := ctx
+		if takesScope(fnType) {
+			fnCtx = withScope(ctx, newScope(ctx, b))
+		}
+
+		args := make([]reflect.Value, fnType.NumIn())
+
+		release, err := b.resolveWithTimeout(fnCtx, cmd, fnType, args)
+		if err != nil {
+			return nil, err
+		}
+
+		release()
+
+		for i := handlerDepsStart(fnType, cmdType); i < fnType.NumIn(); i++ {
+			deps = explainDependency(deps, i, "", fnType.In(i), args[i])
+		}
+	}
+
+	return deps, nil
+}
+
+// explainDependency appends the description of the resolved dependency, recursing into the fields
+// of the dependency structs.
+func explainDependency(deps []ResolvedDep, arg int, field string, t reflect.Type, v reflect.Value) []ResolvedDep {
+	if _, ok := optionalType(t); !ok && t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+			if field != "" {
+				name = field + "." + name
+			}
+
+			deps = explainDependency(deps, arg, name, t.Field(i).Type, v.Field(i))
+		}
+
+		return deps
+	}
+
+	instance := v
+	if _, ok := optionalType(t); ok {
+		instance = v.FieldByName("Value")
+	}
+
+	return append(deps, ResolvedDep{
+		Arg:      arg,
+		Field:    field,
+		Type:     t,
+		Concrete: reflect.TypeOf(instance.Interface()),
+	})
+}
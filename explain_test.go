@@ -0,0 +1,68 @@
+package van
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	type deps struct {
+		Get GetIntService
+		Set SetIntService `van:"optional"`
+	}
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	called := false
+
+	bus.Handle(Command{}, func(cmd *Command, get GetIntService, d deps, set Optional[SetIntService]) error {
+		called = true
+		return nil
+	})
+
+	got, err := bus.Explain(&Command{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ResolvedDep{
+		{Arg: 1, Type: typeOf[GetIntService](), Concrete: typeOf[*GetIntServiceImpl]()},
+		{Arg: 2, Field: "Get", Type: typeOf[GetIntService](), Concrete: typeOf[*GetIntServiceImpl]()},
+		{Arg: 2, Field: "Set", Type: typeOf[SetIntService]()},
+		{Arg: 3, Type: typeOf[Optional[SetIntService]]()},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	if called {
+		t.Fatal("expected the handler not to be called")
+	}
+}
+
+func TestExplainFails(t *testing.T) {
+	bus := New()
+
+	if _, err := bus.Explain(Command{}); !errors.Is(err, ErrHandlerNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := bus.Explain(1); err == nil || err.Error() != "cmd must be a struct, got int" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus = New(WithDeferredValidation())
+	bus.Handle(Command{}, func(cmd *Command, get GetIntService) error {
+		return nil
+	})
+
+	if _, err := bus.Explain(&Command{}); !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/maxpoletaev/van
 
-go 1.17
+go 1.20
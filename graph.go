@@ -0,0 +1,141 @@
+package van
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ValidateGraph walks the dependencies of every registered handler and listener, including the transitive
+// dependencies of their providers, and reports every dependency that has no provider. Unlike Validate, which
+// stops at the first problem, it returns all unresolved dependencies joined into a single error, along with
+// whatever requires them. The providers are never called, only their signatures are inspected.
+func (b *Van) ValidateGraph() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	w := &graphWalker{
+		bus:     b,
+		visited: make(map[providerKey]bool),
+		missing: make(map[string]error),
+	}
+
+	for t, h := range b.handlers {
+		for _, fn := range h.funcs() {
+			fnType := reflect.TypeOf(fn)
+			w.walk(fnType, handlerDepsStart(fnType, t), "handler of "+t.String())
+		}
+	}
+
+	for t, listeners := range b.listeners {
+		for _, l := range listeners {
+			fnType := reflect.TypeOf(l.fn)
+			w.walk(fnType, messageIndex(fnType)+1, "listener of "+t.String())
+		}
+	}
+
+	if len(w.missing) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, 0, len(w.missing))
+	for msg := range w.missing {
+		msgs = append(msgs, msg)
+	}
+
+	sort.Strings(msgs)
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = w.missing[msg]
+	}
+
+	return errors.Join(errs...)
+}
+
+type graphWalker struct {
+	bus     *Van
+	visited map[providerKey]bool
+	missing map[string]error // keyed by the message to report each missing dependency once
+}
+
+// walk visits the arguments of the function starting from the given index.
+func (w *graphWalker) walk(fnType reflect.Type, start int, owner string) {
+	for i := start; i < fnType.NumIn(); i++ {
+		w.visit(fnType.In(i), "", owner)
+	}
+}
+
+func (w *graphWalker) visit(t reflect.Type, name string, owner string) {
+	if depType, ok := optionalType(t); ok {
+		if _, ok := w.bus.providers[providerKey{typ: depType}]; ok {
+			w.visit(depType, "", owner)
+		}
+
+		return
+	}
+
+	if isGroupType(t) {
+		key := providerKey{typ: t}
+		if w.visited[key] {
+			return
+		}
+
+		w.visited[key] = true
+
+		for _, p := range w.bus.groups[t.Elem()] {
+			w.walk(reflect.TypeOf(p.fn), 0, "group provider of "+t.Elem().String())
+		}
+
+		return
+	}
+
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			fieldName, optional := parseTag(field.Tag)
+			if _, ok := w.bus.providers[providerKey{typ: field.Type, name: fieldName}]; optional && !ok {
+				continue
+			}
+
+			w.visit(field.Type, fieldName, owner)
+		}
+
+		return
+	}
+
+	if name == "" && t == typeVan && w.bus.strictBus {
+		err := fmt.Errorf("%w (required by %s)", errStrictBus, owner)
+		w.missing[err.Error()] = err
+
+		return
+	}
+
+	if name == "" && (t == typeVan || t == typePublisher || t == typeDispatcher || t == typeScope || t == typeContext) {
+		return
+	}
+
+	key := providerKey{typ: t, name: name}
+
+	p, ok := w.bus.providers[key]
+	if !ok && name == "" && w.bus.isConcreteType(t) {
+		return
+	}
+
+	if !ok {
+		err := fmt.Errorf("%w for type %s (required by %s)", ErrProviderNotFound, key.String(), owner)
+		w.missing[err.Error()] = err
+
+		return
+	}
+
+	if w.visited[key] {
+		return
+	}
+
+	w.visited[key] = true
+
+	w.walk(reflect.TypeOf(p.fn), 0, "provider of "+key.String())
+}
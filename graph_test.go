@@ -0,0 +1,48 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateGraph(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		return nil
+	})
+
+	bus.Provide(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, deps struct {
+		Get     GetIntService
+		Unknown UnknownService
+	}) {
+	})
+
+	err := bus.ValidateGraph()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	wantErr := "no providers registered for type van.SetIntService (required by provider of van.GetIntService)\n" +
+		"no providers registered for type van.UnknownService (required by listener of van.Event)"
+
+	if err.Error() != wantErr {
+		t.Fatalf("got %q, want %q", err.Error(), wantErr)
+	}
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Provide(func() (UnknownService, error) {
+		return nil, nil
+	})
+
+	if err := bus.ValidateGraph(); err != nil {
+		t.Fatal(err)
+	}
+}
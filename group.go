@@ -0,0 +1,38 @@
+package van
+
+import (
+	"context"
+	"reflect"
+)
+
+// isGroupType reports whether t is a slice of interfaces, which is resolved with the group providers.
+func isGroupType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Interface
+}
+
+// newGroup returns a slice of type t with the instances constructed by all providers of the group.
+func (b *Van) newGroup(ctx context.Context, t reflect.Type) (reflect.Value, error) {
+	elemType := t.Elem()
+
+	b.mu.RLock()
+	group := b.groups[elemType]
+	b.mu.RUnlock()
+
+	value := reflect.MakeSlice(t, 0, len(group))
+
+	for _, provider := range group {
+		inst, err := b.instantiate(ctx, elemType, provider)
+		if err != nil {
+			return reflect.ValueOf(nil), err
+		}
+
+		inst, err = b.intercept(ctx, elemType, inst)
+		if err != nil {
+			return reflect.ValueOf(nil), err
+		}
+
+		value = reflect.Append(value, inst)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,71 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProvideGroup(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return constIntService(0), nil
+	})
+
+	for i := 1; i <= 3; i++ {
+		value := constIntService(i)
+
+		bus.ProvideGroup(func() (GetIntService, error) {
+			return value, nil
+		})
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, all []GetIntService, one GetIntService) error {
+		for _, s := range all {
+			cmd.Result = cmd.Result*10 + s.Get()
+		}
+
+		cmd.Result = cmd.Result*10 + one.Get()
+
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1230 {
+		t.Fatalf("expected 1230, got %d", cmd.Result)
+	}
+}
+
+func TestProvideGroup_Empty(t *testing.T) {
+	bus := New()
+
+	err := bus.Exec(context.Background(), func(all []GetIntService) error {
+		if len(all) != 0 {
+			t.Fatalf("expected an empty group, got %v", all)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvideGroup_NotInterface(t *testing.T) {
+	bus := New()
+
+	// a slice of concrete values is not a group, but a dependency of its own
+	panicsWithError(t, "no providers registered for type []int", func() {
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command, ints []int) error {
+			return nil
+		})
+	})
+
+	panicsWithError(t, "provider cannot return []van.GetIntService, which is resolved from the group providers", func() {
+		bus.Provide(func() ([]GetIntService, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,113 @@
+package van
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Providers returns the types that have providers registered, sorted by name. Each type is listed once,
+// even if it has named or group providers. The returned slice is a copy and can be modified freely.
+func (b *Van) Providers() []reflect.Type {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := make(map[reflect.Type]struct{}, len(b.providers)+len(b.groups))
+	types := make([]reflect.Type, 0, len(b.providers)+len(b.groups))
+
+	add := func(t reflect.Type) {
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			types = append(types, t)
+		}
+	}
+
+	for k := range b.providers {
+		add(k.typ)
+	}
+
+	for t := range b.groups {
+		add(t)
+	}
+
+	sortTypes(types)
+
+	return types
+}
+
+// Handlers returns the command types that have handlers registered, sorted by name.
+// The returned slice is a copy and can be modified freely.
+func (b *Van) Handlers() []reflect.Type {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	types := make([]reflect.Type, 0, len(b.handlers))
+	for t := range b.handlers {
+		types = append(types, t)
+	}
+
+	sortTypes(types)
+
+	return types
+}
+
+// Events returns the number of listeners subscribed to each event type. Event types with no listeners
+// left after unsubscribing are omitted. The catch-all listeners registered with SubscribeAll are counted
+// under the interface{} type. The returned map is a copy and can be modified freely.
+func (b *Van) Events() map[reflect.Type]int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	events := make(map[reflect.Type]int, len(b.listeners))
+
+	for t, listeners := range b.listeners {
+		if len(listeners) > 0 {
+			events[t] = len(listeners)
+		}
+	}
+
+	return events
+}
+
+// HasProvider reports whether the type, which is passed as a nil pointer to the type, e.g. (*Logger)(nil)
+// or (**Config)(nil), has a default provider, e.g. to register a fallback only if there is none yet.
+// Named and group providers are not taken into account. It panics if the argument is not a pointer
+// to a provided type.
+func (b *Van) HasProvider(typePtr interface{}) bool {
+	t, err := providedTypeOf(typePtr)
+	if err != nil {
+		panic(err)
+	}
+
+	return b.hasProvider(t, "")
+}
+
+// HasHandler reports whether the command type has a handler registered. The command may be passed
+// either as a struct or as a pointer to it.
+func (b *Van) HasHandler(cmd interface{}) bool {
+	cmdType := reflect.TypeOf(cmd)
+	if cmdType != nil && cmdType.Kind() == reflect.Ptr {
+		cmdType = cmdType.Elem()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, ok := b.handlers[cmdType]
+
+	return ok
+}
+
+// HasListeners reports whether the event type has at least one listener subscribed. The event may be passed
+// either as a struct or as a pointer to it, same as to Publish.
+func (b *Van) HasListeners(event interface{}) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.listeners[eventType(event)]) > 0
+}
+
+func sortTypes(types []reflect.Type) {
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+}
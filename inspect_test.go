@@ -0,0 +1,120 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideNamed("named", func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideGroup(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		return nil
+	})
+
+	bus.Subscribe(Event{},
+		func(ctx context.Context, event Event) {},
+		func(ctx context.Context, event Event) {},
+	)
+
+	unsubscribe := bus.Subscribe(struct{}{}, func(ctx context.Context, event struct{}) {})
+	unsubscribe()
+
+	wantProviders := []reflect.Type{typeOf[GetIntService](), typeOf[SetIntService]()}
+	if providers := bus.Providers(); !reflect.DeepEqual(providers, wantProviders) {
+		t.Fatalf("got providers %v, want %v", providers, wantProviders)
+	}
+
+	wantHandlers := []reflect.Type{typeOf[Command]()}
+	if handlers := bus.Handlers(); !reflect.DeepEqual(handlers, wantHandlers) {
+		t.Fatalf("got handlers %v, want %v", handlers, wantHandlers)
+	}
+
+	events := bus.Events()
+	wantEvents := map[reflect.Type]int{typeOf[Event](): 2}
+
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Fatalf("got events %v, want %v", events, wantEvents)
+	}
+
+	delete(events, typeOf[Event]())
+
+	if len(bus.Events()) != 1 {
+		t.Fatal("modifying the returned map must not affect the bus")
+	}
+}
+
+func TestHas(t *testing.T) {
+	bus := New()
+
+	if bus.HasProvider((*GetIntService)(nil)) || bus.HasHandler(Command{}) || bus.HasListeners(Event{}) {
+		t.Fatal("expected an empty bus to have nothing registered")
+	}
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideNamed("named", func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		return nil
+	})
+
+	unsubscribe := bus.Subscribe(Event{}, func(ctx context.Context, event Event) {})
+
+	if !bus.HasProvider((*GetIntService)(nil)) {
+		t.Error("expected GetIntService to have a provider")
+	}
+
+	if bus.HasProvider((*SetIntService)(nil)) {
+		t.Error("expected named providers to be ignored")
+	}
+
+	type Config struct{}
+
+	if bus.HasProvider((**Config)(nil)) {
+		t.Error("expected *Config to have no provider")
+	}
+
+	bus.Provide(func() (*Config, error) {
+		return &Config{}, nil
+	})
+
+	if !bus.HasProvider((**Config)(nil)) {
+		t.Error("expected *Config to have a provider")
+	}
+
+	if !bus.HasHandler(Command{}) || !bus.HasHandler(&Command{}) {
+		t.Error("expected Command to have a handler")
+	}
+
+	if !bus.HasListeners(Event{}) || !bus.HasListeners(&Event{}) {
+		t.Error("expected Event to have listeners")
+	}
+
+	unsubscribe()
+
+	if bus.HasListeners(Event{}) {
+		t.Error("expected Event to have no listeners after unsubscribing")
+	}
+
+	panicsWithError(t, "expected a pointer to an interface, struct pointer, slice or map, got int", func() {
+		bus.HasProvider(1)
+	})
+}
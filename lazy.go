@@ -0,0 +1,50 @@
+package van
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// lazyProvider holds the factory of a provider registered with ProvideLazy. It is shared by the copies
+// of the provider made by Clone and Merge, so the factory is called once for all of them.
+type lazyProvider struct {
+	once    sync.Once
+	factory func() ProviderFunc
+	fn      ProviderFunc
+	err     error
+}
+
+// provider returns the provider produced by the factory, checking its signature on the first call.
+func (l *lazyProvider) provider(t reflect.Type) (ProviderFunc, error) {
+	l.once.Do(func() {
+		fn := l.factory()
+		if fn == nil {
+			l.err = fmt.Errorf("lazy provider of %s is nil", t.String())
+			return
+		}
+
+		fnType := reflect.TypeOf(fn)
+
+		if err := validateProviderSignature(fnType); err != nil {
+			l.err = fmt.Errorf("invalid lazy provider of %s: %w", t.String(), err)
+			return
+		}
+
+		if fnType.Out(0) != t {
+			l.err = fmt.Errorf("lazy provider of %s returns %s", t.String(), fnType.Out(0).String())
+			return
+		}
+
+		for i := 0; i < fnType.NumIn(); i++ {
+			if fnType.In(i) == t {
+				l.err = fmt.Errorf("lazy provider of %s has a dependency of the same type", t.String())
+				return
+			}
+		}
+
+		l.fn = fn
+	})
+
+	return l.fn, l.err
+}
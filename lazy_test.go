@@ -0,0 +1,89 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProvideLazy(t *testing.T) {
+	var factoryCalls, providerCalls int
+
+	bus := New()
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.ProvideLazy((*GetIntService)(nil), func() ProviderFunc {
+		factoryCalls++
+
+		return func(s SetIntService) (GetIntService, error) {
+			providerCalls++
+			return &GetIntServiceImpl{}, nil
+		}
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		cmd.Result = get.Get()
+		return nil
+	})
+
+	if factoryCalls != 0 {
+		t.Fatal("expected the factory not to be called before the dependency is needed")
+	}
+
+	for i := 0; i < 2; i++ {
+		cmd := &Command{}
+		if err := bus.Invoke(context.Background(), cmd); err != nil {
+			t.Fatal(err)
+		}
+
+		if cmd.Result != 1 {
+			t.Fatalf("expected result 1, got %d", cmd.Result)
+		}
+	}
+
+	if factoryCalls != 1 || providerCalls != 2 {
+		t.Fatalf("expected 1 factory call and 2 provider calls, got %d and %d", factoryCalls, providerCalls)
+	}
+}
+
+func TestProvideLazyFails(t *testing.T) {
+	tests := map[string]struct {
+		factory func() ProviderFunc
+		wantErr string
+	}{
+		"nil provider": {
+			factory: func() ProviderFunc { return nil },
+			wantErr: "lazy provider of van.GetIntService is nil",
+		},
+		"invalid signature": {
+			factory: func() ProviderFunc { return func() GetIntService { return nil } },
+			wantErr: "invalid lazy provider of van.GetIntService: provider must have two return values, got 1",
+		},
+		"wrong type": {
+			factory: func() ProviderFunc { return func() (SetIntService, error) { return nil, nil } },
+			wantErr: "lazy provider of van.GetIntService returns van.SetIntService",
+		},
+		"same type dependency": {
+			factory: func() ProviderFunc { return func(GetIntService) (GetIntService, error) { return nil, nil } },
+			wantErr: "lazy provider of van.GetIntService has a dependency of the same type",
+		},
+		"missing dependency": {
+			factory: func() ProviderFunc { return func(SetIntService) (GetIntService, error) { return nil, nil } },
+			wantErr: "no providers registered for type van.SetIntService",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bus := New()
+			bus.ProvideLazy((*GetIntService)(nil), tt.factory)
+
+			_, err := Resolve[GetIntService](context.Background(), bus)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+type listenerOpts struct {
+	fn ListenerFunc
+
+	attempts   int
+	backoff    func(attempt int) time.Duration
+	deadLetter DeadLetterFunc
+	priority   int
+}
+
+// deadLetterSink returns the dead letter sink of the listener, falling back to the global one of the bus.
+func (l *listenerOpts) deadLetterSink(b *Van) DeadLetterFunc {
+	if l.deadLetter != nil {
+		return l.deadLetter
+	}
+
+	return b.deadLetter
+}
+
+// ListenerOption configures the listeners registered with Subscribe. Options are passed to Subscribe
+// along with the listeners and apply to all listeners of the call, regardless of their position.
+type ListenerOption func(*listenerOpts)
+
+// DeadLetterFunc receives the events that the listener has failed to process, along with the last error.
+type DeadLetterFunc func(ctx context.Context, event interface{}, err error)
+
+// WithRetry makes the bus call the listener up to the given number of attempts until it succeeds.
+// The backoff function returns the delay before the next attempt, given the number of the failed attempt
+// starting from 1. It may be nil, in which case the listener is retried immediately.
+func WithRetry(attempts int, backoff func(attempt int) time.Duration) ListenerOption {
+	return func(l *listenerOpts) {
+		l.attempts = attempts
+		l.backoff = backoff
+	}
+}
+
+// WithDeadLetter makes the bus pass the events that the listener has failed to process to the sink,
+// instead of logging the failure. When combined with WithRetry, the sink is only called once all
+// attempts have failed, and receives the error of the last attempt.
+func WithDeadLetter(sink DeadLetterFunc) ListenerOption {
+	return func(l *listenerOpts) {
+		l.deadLetter = sink
+	}
+}
+
+func withPriority(priority int) ListenerOption {
+	return func(l *listenerOpts) {
+		l.priority = priority
+	}
+}
+
+// runListener processes the event with a single listener. Each attempt resolves the dependencies and calls
+// the listener, recovering from its panic, so that the other listeners of the event still run. Failed attempts
+// are retried with the backoff until the attempts are exhausted or the context is cancelled. The final failure
+// is either passed to the dead letter sink or reported. Failures to close the dependencies are reported,
+// but not retried.
+func (b *Van) runListener(ctx context.Context, event interface{}, l *listenerOpts, report func(error)) {
+	attempts := l.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+
+	if b.tracer != nil {
+		var end func(error)
+
+		ctx, end = b.tracer.StartSpan(ctx, "listener "+reflect.TypeOf(event).String())
+		defer func() { end(err) }()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = b.runListenerOnce(ctx, event, l, report)
+		if err == nil || attempt >= attempts {
+			break
+		}
+
+		// the last failure of the listener is reported, rather than the cancellation of the context
+		if sleepContext(ctx, l.backoff, attempt) != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		return
+	}
+
+	if sink := l.deadLetterSink(b); sink != nil {
+		sink(ctx, event, err)
+		return
+	}
+
+	report(err)
+}
+
+func (b *Van) runListenerOnce(ctx context.Context, event interface{}, l *listenerOpts, report func(error)) (err error) {
+	typ := reflect.TypeOf(l.fn)
+
+	var stack [maxArgs]reflect.Value
+
+	numIn := typ.NumIn()
+	args := argsBuffer(stack[:], numIn)
+
+	var cl *closers
+
+	listenerCtx := ctx
+	if b.hasAutoClose.Load() {
+		cl = &closers{}
+		listenerCtx = withClosers(ctx, cl)
+	}
+
+	defer func() {
+		if closeErr := cl.close(); closeErr != nil {
+			report(fmt.Errorf("failed to close dependencies of %s: %w", typ.String(), closeErr))
+		}
+	}()
+
+	// the providers of the listener's dependencies may panic as well
+	defer func() {
+		if r := recover(); r != nil {
+			err = b.panicError(r, "listener", reflect.TypeOf(event), funcName(l.fn))
+		}
+	}()
+
+	if numIn > 0 {
+		if err := b.resolve(listenerCtx, event, typ, args[:numIn]); err != nil {
+			return fmt.Errorf("failed to resolve dependencies for %s: %w", typ.String(), err)
+		}
+	}
+
+	if err := b.callListener(ctx, l.fn, args[:numIn]); err != nil {
+		return fmt.Errorf("listener of %s failed: %w", reflect.TypeOf(event).String(), err)
+	}
+
+	return nil
+}
+
+// sleepContext waits for the backoff delay of the given attempt, returning early if the context is cancelled.
+func sleepContext(ctx context.Context, backoff func(attempt int) time.Duration, attempt int) error {
+	if backoff == nil {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
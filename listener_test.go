@@ -0,0 +1,451 @@
+package van
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribe_RetryAndDeadLetter(t *testing.T) {
+	var (
+		attempts int
+		backoffs []int
+		dead     []interface{}
+		deadErr  error
+	)
+
+	listener := func(ctx context.Context, event Event) {
+		attempts++
+		panic(fmt.Sprintf("attempt %d", attempts))
+	}
+
+	backoff := func(attempt int) time.Duration {
+		backoffs = append(backoffs, attempt)
+		return time.Millisecond
+	}
+
+	sink := func(ctx context.Context, event interface{}, err error) {
+		dead = append(dead, event)
+		deadErr = err
+	}
+
+	bus := New()
+	bus.Subscribe(Event{}, listener, WithRetry(3, backoff), WithDeadLetter(sink))
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if attempts != 3 {
+		t.Fatalf("attempts != 3, got %d", attempts)
+	}
+
+	if len(backoffs) != 2 || backoffs[0] != 1 || backoffs[1] != 2 {
+		t.Fatalf("unexpected backoff calls: %v", backoffs)
+	}
+
+	if len(dead) != 1 || dead[0] != (Event{}) {
+		t.Fatalf("event is not in the dead letter sink: %v", dead)
+	}
+
+	if deadErr == nil || !strings.Contains(deadErr.Error(), "attempt 3") {
+		t.Fatalf("expected the error of the last attempt, got %v", deadErr)
+	}
+}
+
+func TestSubscribe_RetryCancelled(t *testing.T) {
+	var deadErr error
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener := func(ctx context.Context, event Event) error {
+		cancel()
+		return errors.New("listener failed")
+	}
+
+	sink := func(ctx context.Context, event interface{}, err error) {
+		deadErr = err
+	}
+
+	bus := New()
+	bus.Subscribe(Event{}, listener, WithRetry(3, nil), WithDeadLetter(sink))
+
+	if err := bus.PublishSync(ctx, Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if deadErr == nil || !strings.Contains(deadErr.Error(), "listener failed") {
+		t.Fatalf("expected the error of the listener, got %v", deadErr)
+	}
+}
+
+func TestSubscribe_RetrySucceeds(t *testing.T) {
+	var attempts, deadLetters int
+
+	listener := func(ctx context.Context, event Event) {
+		attempts++
+		if attempts < 2 {
+			panic("fail")
+		}
+	}
+
+	sink := func(ctx context.Context, event interface{}, err error) {
+		deadLetters++
+	}
+
+	bus := New()
+	bus.Subscribe(Event{}, WithRetry(3, nil), WithDeadLetter(sink), listener)
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if attempts != 2 {
+		t.Fatalf("attempts != 2, got %d", attempts)
+	}
+
+	if deadLetters != 0 {
+		t.Fatalf("deadLetters != 0, got %d", deadLetters)
+	}
+}
+
+func TestSubscribe_DeadLetterResolveError(t *testing.T) {
+	var deadErr error
+
+	listener := func(ctx context.Context, event Event, svc SetIntService) {}
+
+	sink := func(ctx context.Context, event interface{}, err error) {
+		deadErr = err
+	}
+
+	bus := New(WithDeferredValidation())
+	bus.Subscribe(Event{}, listener, WithDeadLetter(sink))
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if deadErr == nil || !strings.Contains(deadErr.Error(), "failed to resolve dependencies") {
+		t.Fatalf("expected a resolution error, got %v", deadErr)
+	}
+}
+
+func TestPublishSync(t *testing.T) {
+	var called []string
+
+	bus := New(WithDeferredValidation())
+
+	bus.Subscribe(Event{},
+		func(ctx context.Context, event Event) {
+			called = append(called, "first")
+			panic("first failed")
+		},
+		func(ctx context.Context, event Event) {
+			called = append(called, "second")
+		},
+		func(ctx context.Context, event Event, svc UnknownService) {
+			called = append(called, "third")
+		},
+	)
+
+	err := bus.PublishSync(context.Background(), Event{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	wantErr := "listener van.TestPublishSync.func1 of van.Event panicked: first failed\n" +
+		"failed to resolve dependencies for func(context.Context, van.Event, van.UnknownService): " +
+		"no providers registered for type van.UnknownService"
+
+	if err.Error() != wantErr {
+		t.Fatalf("got %q, want %q", err.Error(), wantErr)
+	}
+
+	if strings.Join(called, ",") != "first,second" {
+		t.Fatalf("unexpected listener calls: %v", called)
+	}
+}
+
+func TestPublishSync_WaitsForListeners(t *testing.T) {
+	var done bool
+
+	bus := New()
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		time.Sleep(10 * time.Millisecond)
+		done = true
+	})
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !done {
+		t.Fatal("PublishSync returned before the listener completed")
+	}
+}
+
+func TestPublishSync_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+
+	bus := New()
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := bus.PublishSync(ctx, Event{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	close(release)
+	bus.Wait()
+}
+
+func TestSubscribe_Unsubscribe(t *testing.T) {
+	var calls []string
+
+	bus := New()
+
+	unsubscribe := bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		calls = append(calls, "first")
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		calls = append(calls, "second")
+	})
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+	unsubscribe()
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls, ",") != "first,second,second" {
+		t.Fatalf("unexpected listener calls: %v", calls)
+	}
+}
+
+func TestSubscribe_UnsubscribeConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	bus := New()
+
+	for i := 0; i < 10; i++ {
+		unsubscribe := bus.Subscribe(Event{}, func(ctx context.Context, event Event) {})
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			_ = bus.PublishSync(context.Background(), Event{})
+		}()
+
+		unsubscribe()
+	}
+
+	wg.Wait()
+}
+
+func TestSubscribeWithPriority(t *testing.T) {
+	var calls []string
+
+	listener := func(name string) func(ctx context.Context, event Event) {
+		return func(ctx context.Context, event Event) {
+			calls = append(calls, name)
+		}
+	}
+
+	bus := New()
+
+	bus.Subscribe(Event{}, listener("default"))
+	bus.SubscribeWithPriority(Event{}, -1, listener("low"))
+	bus.SubscribeWithPriority(Event{}, 10, listener("high1"), listener("high2"))
+	bus.SubscribeWithPriority(Event{}, 10, listener("high3"))
+	bus.SubscribeWithPriority(Event{}, 5, listener("medium"))
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "high1,high2,high3,medium,default,low"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestWithGlobalDeadLetter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		global  []error
+		ownSink int
+	)
+
+	sink := func(ctx context.Context, event interface{}, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if event != (Event{Value: 1}) {
+			t.Errorf("unexpected event: %v", event)
+		}
+
+		global = append(global, err)
+	}
+
+	bus := New(WithGlobalDeadLetter(sink))
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		panic("failed")
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		panic("failed with own sink")
+	}, WithDeadLetter(func(ctx context.Context, event interface{}, err error) {
+		ownSink++
+	}))
+
+	if err := bus.Publish(Event{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if len(global) != 1 || global[0].Error() != "listener van.TestWithGlobalDeadLetter.func2 of van.Event panicked: failed" {
+		t.Fatalf("unexpected dead letters: %v", global)
+	}
+
+	if ownSink != 1 {
+		t.Fatalf("expected the listener's own sink to take precedence, got %d calls", ownSink)
+	}
+}
+
+func TestSubscribe_ReturnsError(t *testing.T) {
+	var attempts int
+
+	listenerErr := errors.New("failed")
+
+	bus := New()
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) error {
+		attempts++
+		return listenerErr
+	}, WithRetry(2, nil))
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) error {
+		return nil
+	})
+
+	err := bus.PublishSync(context.Background(), Event{})
+	if !errors.Is(err, listenerErr) || err.Error() != "listener of van.Event failed: failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected the failed listener to be retried, got %d attempts", attempts)
+	}
+}
+
+func panickingListener(ctx context.Context, event Event) {
+	panic("boom")
+}
+
+func TestPublish_ListenerPanics(t *testing.T) {
+	var buf bytes.Buffer
+
+	called := false
+
+	bus := New(WithLogger(log.New(&buf, "", 0)))
+	bus.Subscribe(Event{}, panickingListener)
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		called = true
+	})
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if !called {
+		t.Fatal("expected the next listener to be called")
+	}
+
+	want := "van: listener van.panickingListener of van.Event panicked: boom\n"
+
+	if output := buf.String(); output != want {
+		t.Fatalf("got %q, want %q", output, want)
+	}
+}
+
+func TestSubscribeAll(t *testing.T) {
+	var called []string
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		called = append(called, "event")
+	})
+
+	unsubscribe := bus.SubscribeAll(func(ctx context.Context, event interface{}, svc GetIntService) {
+		called = append(called, fmt.Sprintf("all %T %d", event, svc.Get()))
+	})
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishSync(context.Background(), struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event,all van.Event 1,all struct {} 1,event"
+	if strings.Join(called, ",") != want {
+		t.Fatalf("got %v, want %s", called, want)
+	}
+}
+
+func TestSubscribeAll_Fails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "event type mismatch", func() {
+		bus.SubscribeAll(func(ctx context.Context, event Event) {})
+	})
+
+	panicsWithError(t, "event type mismatch", func() {
+		bus.Subscribe(Event{}, func(ctx context.Context, event interface{}) {})
+	})
+
+	panicsWithError(t, "no providers registered for type van.UnknownService", func() {
+		bus.SubscribeAll(func(ctx context.Context, event interface{}, svc UnknownService) {})
+	})
+}
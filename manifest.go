@@ -0,0 +1,148 @@
+package van
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Manifest describes the registrations of the bus. It is written by WriteManifest.
+type Manifest struct {
+	Providers []ManifestProvider `json:"providers"`
+	Handlers  []ManifestHandler  `json:"handlers"`
+	Listeners []ManifestListener `json:"listeners"`
+}
+
+// ManifestProvider describes a registered provider. Lifetime is either "transient", "scoped" or "singleton".
+type ManifestProvider struct {
+	Type         string   `json:"type"`
+	Name         string   `json:"name,omitempty"`
+	Group        bool     `json:"group,omitempty"`
+	Lifetime     string   `json:"lifetime"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// ManifestHandler describes a registered command handler.
+type ManifestHandler struct {
+	Command      string   `json:"command"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// ManifestListener describes a registered event listener.
+type ManifestListener struct {
+	Event        string   `json:"event"`
+	Dependencies []string `json:"dependencies"`
+}
+
+// WriteManifest writes the JSON manifest of the registered providers, handlers and listeners to w.
+// Dependencies are listed in the order of the function arguments, excluding the command or event argument.
+// Providers and handlers are sorted by type, with the handlers of a pipeline in the order of execution.
+// Listeners are sorted by event type and then by the order of registration, so the output for the same set
+// of registrations is always the same and can be compared against a golden file to catch unintended wiring
+// changes.
+func (b *Van) WriteManifest(w io.Writer) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	m := Manifest{
+		Providers: make([]ManifestProvider, 0, len(b.providers)),
+		Handlers:  make([]ManifestHandler, 0, len(b.handlers)),
+		Listeners: make([]ManifestListener, 0),
+	}
+
+	for k, p := range b.providers {
+		m.Providers = append(m.Providers, manifestProvider(k.typ, k.name, p))
+	}
+
+	for t, group := range b.groups {
+		for _, p := range group {
+			m.Providers = append(m.Providers, manifestProvider(t, "", p))
+		}
+	}
+
+	for t, h := range b.handlers {
+		for _, fn := range h.funcs() {
+			fnType := reflect.TypeOf(fn)
+
+			skip := messageIndex(fnType)
+			if !takesCommand(fnType, t) {
+				skip = -1
+			}
+
+			m.Handlers = append(m.Handlers, ManifestHandler{
+				Command:      t.String(),
+				Dependencies: dependencyNames(fnType, skip),
+			})
+		}
+	}
+
+	for t, listeners := range b.listeners {
+		for _, listener := range listeners {
+			m.Listeners = append(m.Listeners, ManifestListener{
+				Event:        t.String(),
+				Dependencies: dependencyNames(reflect.TypeOf(listener.fn), messageIndex(reflect.TypeOf(listener.fn))),
+			})
+		}
+	}
+
+	// group providers of the same type are kept in the order of registration
+	sort.SliceStable(m.Providers, func(i, j int) bool {
+		p, q := m.Providers[i], m.Providers[j]
+
+		switch {
+		case p.Type != q.Type:
+			return p.Type < q.Type
+		case p.Group != q.Group:
+			return !p.Group
+		default:
+			return p.Name < q.Name
+		}
+	})
+
+	// the handlers of a pipeline are kept in the order of execution
+	sort.SliceStable(m.Handlers, func(i, j int) bool {
+		return m.Handlers[i].Command < m.Handlers[j].Command
+	})
+
+	sort.SliceStable(m.Listeners, func(i, j int) bool {
+		return m.Listeners[i].Event < m.Listeners[j].Event
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(m)
+}
+
+// dependencyNames returns the names of the function argument types, skipping the argument at the given index.
+func dependencyNames(fnType reflect.Type, skip int) []string {
+	names := make([]string, 0, fnType.NumIn())
+
+	for i := 0; i < fnType.NumIn(); i++ {
+		if i != skip {
+			names = append(names, fnType.In(i).String())
+		}
+	}
+
+	return names
+}
+
+func manifestProvider(t reflect.Type, name string, p *providerOpts) ManifestProvider {
+	lifetime := "transient"
+
+	switch {
+	case p.singleton:
+		lifetime = "singleton"
+	case p.scoped:
+		lifetime = "scoped"
+	}
+
+	return ManifestProvider{
+		Type:         t.String(),
+		Name:         name,
+		Group:        p.group,
+		Lifetime:     lifetime,
+		Dependencies: dependencyNames(reflect.TypeOf(p.fn), -1),
+	}
+}
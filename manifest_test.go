@@ -0,0 +1,83 @@
+package van
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func newManifestBus() *Van {
+	bus := New()
+
+	bus.ProvideOnce(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Provide(func(ctx context.Context, s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		return nil
+	})
+
+	bus.Subscribe(Event{},
+		func(ctx context.Context, event Event, s SetIntService) {},
+		func(ctx context.Context, event Event, b *Van) {},
+	)
+
+	return bus
+}
+
+func TestWriteManifest(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := newManifestBus().WriteManifest(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Manifest
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Manifest{
+		Providers: []ManifestProvider{
+			{Type: "van.GetIntService", Lifetime: "transient", Dependencies: []string{"context.Context", "van.SetIntService"}},
+			{Type: "van.SetIntService", Lifetime: "singleton", Dependencies: []string{}},
+		},
+		Handlers: []ManifestHandler{
+			{Command: "van.Command", Dependencies: []string{"context.Context", "van.GetIntService"}},
+		},
+		Listeners: []ManifestListener{
+			{Event: "van.Event", Dependencies: []string{"context.Context", "van.SetIntService"}},
+			{Event: "van.Event", Dependencies: []string{"context.Context", "*van.Van"}},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteManifest_Deterministic(t *testing.T) {
+	var first, second bytes.Buffer
+
+	if err := newManifestBus().WriteManifest(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		second.Reset()
+
+		if err := newManifestBus().WriteManifest(&second); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("manifests differ:\n%s\n%s", first.String(), second.String())
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package van
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type mergeOpts struct {
+	preferExisting bool
+}
+
+// MergeOption configures the behaviour of Merge.
+type MergeOption func(*mergeOpts)
+
+// PreferExisting makes Merge keep the registrations of the target bus in case of conflicts,
+// instead of failing.
+func PreferExisting() MergeOption {
+	return func(o *mergeOpts) {
+		o.preferExisting = true
+	}
+}
+
+// Merge copies the providers, handlers, listeners and decorators of the other bus into this one. By default, it fails
+// if both buses have a provider for the same type or a handler for the same command, and reports all
+// conflicts at once without modifying the bus. Listeners never conflict, as there can be any number of them.
+// Singleton instances that have already been constructed by the other bus are carried over. The options
+// of the other bus, such as the resolve timeout, are not merged. A bus cannot be merged into itself.
+func (b *Van) Merge(other *Van, opts ...MergeOption) error {
+	o := mergeOpts{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if other == b {
+		return fmt.Errorf("cannot merge the bus into itself")
+	}
+
+	// the other bus is copied before locking this one, so that the buses merged into each other
+	// at the same time never wait for each other's locks
+	other = other.snapshot()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !o.preferExisting {
+		if err := b.mergeConflicts(other); err != nil {
+			return err
+		}
+	}
+
+	for k, p := range other.providers {
+		if _, ok := b.providers[k]; ok {
+			continue
+		}
+
+		b.providers[k] = p.clone(true)
+
+		if p.autoClose {
+			b.hasAutoClose.Store(true)
+		}
+
+		if p.scoped {
+			b.hasScoped.Store(true)
+		}
+	}
+
+	for t, group := range other.groups {
+		for _, p := range group {
+			b.groups[t] = append(b.groups[t], p.clone(true))
+
+			if p.autoClose {
+				b.hasAutoClose.Store(true)
+			}
+
+			if p.scoped {
+				b.hasScoped.Store(true)
+			}
+		}
+	}
+
+	for t, h := range other.handlers {
+		if _, ok := b.handlers[t]; ok {
+			continue
+		}
+
+		b.addHandler(t, h.clone(), nil)
+	}
+
+	for t, listeners := range other.listeners {
+		merged := make([]*listenerOpts, 0, len(b.listeners[t])+len(listeners))
+		merged = append(merged, b.listeners[t]...)
+		merged = append(merged, listeners...)
+
+		sort.SliceStable(merged, func(i, j int) bool {
+			return merged[i].priority > merged[j].priority
+		})
+
+		b.listeners[t] = merged
+	}
+
+	for _, instance := range other.concrete {
+		if instance != nil {
+			b.addConcrete(instance)
+		}
+	}
+
+	for t, decorators := range other.decorators {
+		merged := make([]Decorator, 0, len(b.decorators[t])+len(decorators))
+		merged = append(merged, b.decorators[t]...)
+		merged = append(merged, decorators...)
+
+		b.decorators[t] = merged
+	}
+
+	return nil
+}
+
+// snapshot returns a bus holding copies of the registrations of this one, which is only meant to be read.
+// The providers and handlers themselves are shared, as are the listener and decorator slices, since they
+// are never modified in place.
+func (b *Van) snapshot() *Van {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	s := &Van{
+		providers:  make(map[providerKey]*providerOpts, len(b.providers)),
+		groups:     make(map[reflect.Type][]*providerOpts, len(b.groups)),
+		listeners:  make(map[reflect.Type][]*listenerOpts, len(b.listeners)),
+		handlers:   make(map[reflect.Type]*handlerOpts, len(b.handlers)),
+		decorators: make(map[reflect.Type][]Decorator, len(b.decorators)),
+		concrete:   make(map[reflect.Type]interface{}, len(b.concrete)),
+	}
+
+	for k, p := range b.providers {
+		s.providers[k] = p
+	}
+
+	for t, group := range b.groups {
+		s.groups[t] = append([]*providerOpts(nil), group...)
+	}
+
+	for t, listeners := range b.listeners {
+		s.listeners[t] = listeners
+	}
+
+	for t, h := range b.handlers {
+		s.handlers[t] = h
+	}
+
+	for t, decorators := range b.decorators {
+		s.decorators[t] = decorators
+	}
+
+	for t, instance := range b.concrete {
+		s.concrete[t] = instance
+	}
+
+	return s
+}
+
+func (b *Van) mergeConflicts(other *Van) error {
+	var conflicts []string
+
+	for k := range other.providers {
+		if _, ok := b.providers[k]; ok {
+			conflicts = append(conflicts, "provider for "+k.String())
+		}
+	}
+
+	for t := range other.handlers {
+		if _, ok := b.handlers[t]; ok {
+			conflicts = append(conflicts, "handler for "+t.String())
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	sort.Strings(conflicts)
+
+	return fmt.Errorf("merge conflicts: %s", strings.Join(conflicts, ", "))
+}
+
+// clone returns a copy of the provider options, optionally carrying over the singleton instance.
+func (p *providerOpts) clone(withInstance bool) *providerOpts {
+	p.RLock()
+	defer p.RUnlock()
+
+	c := &providerOpts{
+		fn:            p.fn,
+		name:          p.name,
+		singleton:     p.singleton,
+		takesContext:  p.takesContext,
+		autoClose:     p.autoClose,
+		usesAutoClose: p.usesAutoClose,
+		cleanup:       p.cleanup,
+		group:         p.group,
+		scoped:        p.scoped,
+		lazy:          p.lazy,
+	}
+
+	if p.singleton {
+		c.building = make(chan struct{}, 1)
+	}
+
+	if withInstance {
+		c.instance = p.instance
+	}
+
+	return c
+}
+
+// clone returns a copy of the handler options that is not bound to any bus yet.
+func (h *handlerOpts) clone() *handlerOpts {
+	return &handlerOpts{
+		fn:          h.fn,
+		idempotency: h.idempotency,
+		middleware:  h.middleware,
+		typed:       h.typed,
+		timeout:     h.timeout,
+		retry:       h.retry,
+		pipeline:    h.pipeline,
+	}
+}
@@ -0,0 +1,132 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	var listenerCalls int
+
+	getIntService := &GetIntServiceImpl{}
+
+	first := New()
+	first.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+	first.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		listenerCalls++
+	})
+
+	// the handler depends on a provider from the first bus
+	second := New(WithDeferredValidation())
+	second.ProvideOnce(func() (GetIntService, error) {
+		return getIntService, nil
+	})
+	second.Handle(Command{}, func(ctx context.Context, cmd *Command, g GetIntService, s SetIntService) error {
+		cmd.Result = g.Get()
+		return nil
+	})
+	second.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		listenerCalls++
+	})
+
+	// construct the singleton before merging to check it is carried over
+	if _, err := Resolve[GetIntService](context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Merge(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &Command{}
+	if err := first.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected result 1, got %d", cmd.Result)
+	}
+
+	if first.providers[providerKey{typ: typeOf[GetIntService]()}].instance != getIntService {
+		t.Fatal("expected the singleton instance to be carried over")
+	}
+
+	if err := first.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	first.Wait()
+
+	if listenerCalls != 2 {
+		t.Fatalf("listenerCalls != 2, got %d", listenerCalls)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	newBus := func(result int) *Van {
+		bus := New()
+		bus.Provide(func() (GetIntService, error) {
+			return &GetIntServiceImpl{}, nil
+		})
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+			cmd.Result = result
+			return nil
+		})
+
+		return bus
+	}
+
+	first, second := newBus(1), newBus(2)
+
+	wantErr := "merge conflicts: handler for van.Command, provider for van.GetIntService"
+	if err := first.Merge(second); err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+
+	if err := first.Merge(second, PreferExisting()); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &Command{}
+	if err := first.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected the existing handler to be kept, got result %d", cmd.Result)
+	}
+}
+
+func TestMerge_Itself(t *testing.T) {
+	bus := New()
+
+	if err := bus.Merge(bus); err == nil || err.Error() != "cannot merge the bus into itself" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMerge_Concurrent(t *testing.T) {
+	first, second := New(), New()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			_ = first.Merge(second, PreferExisting())
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_ = second.Merge(first, PreferExisting())
+	}
+
+	<-done
+}
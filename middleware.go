@@ -0,0 +1,62 @@
+package van
+
+import (
+	"context"
+)
+
+// InvokeFunc processes a command. This is how the command handling is seen by middleware.
+type InvokeFunc func(ctx context.Context, cmd interface{}) error
+
+// Middleware wraps the command handling with cross-cutting logic, such as logging or metrics.
+// It can modify the context passed further, or short-circuit by not calling next at all.
+type Middleware func(next InvokeFunc) InvokeFunc
+
+// WithMiddleware wraps the handling of a single command type with the given middleware.
+// The first middleware is the outermost one. The middleware runs before the dependencies
+// are resolved, so that the context passed to next is also used for dependency resolution.
+func WithMiddleware(mw ...Middleware) HandlerOption {
+	return func(h *handlerOpts) {
+		h.middleware = append(h.middleware, mw...)
+	}
+}
+
+// Use wraps the handling of all commands with the given middleware, including the handlers registered
+// later. Global middleware runs in registration order around the middleware of the individual handlers.
+func (b *Van) Use(mw ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.middleware = append(b.middleware, mw...)
+
+	// the handlers are replaced rather than modified, as they might be in use by Invoke
+	for t, h := range b.handlers {
+		h = h.clone()
+		b.buildChain(h)
+		b.handlers[t] = h
+	}
+}
+
+// buildChain precomputes the middleware chain of the handler, so that Invoke does not have to do it on every call.
+func (b *Van) buildChain(h *handlerOpts) {
+	if len(b.middleware) == 0 && len(h.middleware) == 0 {
+		h.chain = nil
+		return
+	}
+
+	mw := make([]Middleware, 0, len(b.middleware)+len(h.middleware))
+	mw = append(mw, b.middleware...)
+	mw = append(mw, h.middleware...)
+
+	h.chain = chain(mw, func(ctx context.Context, cmd interface{}) error {
+		return b.handle(ctx, h, cmd)
+	})
+}
+
+// chain wraps the function with the middleware so that the first middleware is the outermost one.
+func chain(mw []Middleware, fn InvokeFunc) InvokeFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		fn = mw[i](fn)
+	}
+
+	return fn
+}
@@ -0,0 +1,114 @@
+package van
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+
+	record := func(name string) Middleware {
+		return func(next InvokeFunc) InvokeFunc {
+			return func(ctx context.Context, cmd interface{}) error {
+				calls = append(calls, name)
+				return next(ctx, cmd)
+			}
+		}
+	}
+
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		calls = append(calls, "handler")
+		return nil
+	}, WithMiddleware(record("first"), record("second")))
+
+	bus.Handle(benchCommand{}, func(ctx context.Context, cmd *benchCommand) error {
+		calls = append(calls, "other handler")
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Invoke(context.Background(), &benchCommand{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "handler", "other handler"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+}
+
+func TestWithMiddleware_ShortCircuit(t *testing.T) {
+	var handlerExecuted int
+
+	wantErr := errors.New("forbidden")
+
+	deny := func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, cmd interface{}) error {
+			return wantErr
+		}
+	}
+
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		handlerExecuted++
+		return nil
+	}, WithMiddleware(deny))
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+
+	if handlerExecuted != 0 {
+		t.Fatalf("handlerExecuted != 0, got %d", handlerExecuted)
+	}
+}
+
+func TestUse(t *testing.T) {
+	var calls []string
+
+	record := func(name string) Middleware {
+		return func(next InvokeFunc) InvokeFunc {
+			return func(ctx context.Context, cmd interface{}) error {
+				calls = append(calls, name)
+				return next(ctx, cmd)
+			}
+		}
+	}
+
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		calls = append(calls, "handler")
+		return nil
+	}, WithMiddleware(record("local")))
+
+	bus.Use(record("first"), record("second"))
+
+	bus.Handle(benchCommand{}, func(ctx context.Context, cmd *benchCommand) error {
+		calls = append(calls, "other handler")
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Invoke(context.Background(), &benchCommand{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"first", "second", "local", "handler", "first", "second", "other handler"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+}
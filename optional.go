@@ -0,0 +1,76 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// Optional is a dependency that is only injected if there is a provider registered for type T.
+// Otherwise, Valid is false and Value is nil. T must be an interface type. It can be used as a function
+// argument, while the fields of dependency structs are made optional with the `van:"optional"` tag.
+// The dependency is only optional in the sense of registration, provider errors are still returned.
+type Optional[T any] struct {
+	Value T
+	Valid bool
+}
+
+func (Optional[T]) dependencyType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+type optionalDependency interface {
+	dependencyType() reflect.Type
+}
+
+var typeOptionalDependency = reflect.TypeOf((*optionalDependency)(nil)).Elem()
+
+// optionalType returns the type wrapped into Optional, if t is an instantiation of Optional.
+func optionalType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || !t.Implements(typeOptionalDependency) {
+		return nil, false
+	}
+
+	return reflect.Zero(t).Interface().(optionalDependency).dependencyType(), true
+}
+
+// parseTag parses the `van:"name,optional"` tag of a dependency struct field.
+// Both parts are optional, e.g. `van:"redis"` or `van:"optional"`.
+func parseTag(tag reflect.StructTag) (name string, optional bool) {
+	parts := strings.Split(tag.Get("van"), ",")
+
+	for _, part := range parts {
+		if part == "optional" {
+			optional = true
+			continue
+		}
+
+		name = part
+	}
+
+	return name, optional
+}
+
+func (b *Van) hasProvider(t reflect.Type, name string) bool {
+	_, ok := b.provider(providerKey{typ: t, name: name})
+	return ok
+}
+
+// buildOptional constructs the Optional wrapper of type t, leaving it empty if there is no provider.
+func (b *Van) buildOptional(ctx context.Context, t, depType reflect.Type) (reflect.Value, error) {
+	value := reflect.New(t).Elem()
+
+	if !b.hasProvider(depType, "") {
+		return value, nil
+	}
+
+	instance, err := b.new(ctx, depType)
+	if err != nil {
+		return reflect.ValueOf(nil), err
+	}
+
+	value.Field(0).Set(instance)
+	value.Field(1).SetBool(true)
+
+	return value, nil
+}
@@ -0,0 +1,75 @@
+package van
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOptional(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	err := bus.Exec(context.Background(), func(get Optional[GetIntService], set Optional[SetIntService]) error {
+		if !get.Valid || get.Value == nil {
+			t.Fatal("expected the registered dependency to be injected")
+		}
+
+		if set.Valid || set.Value != nil {
+			t.Fatal("expected the unregistered dependency to be empty")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptional_StructTag(t *testing.T) {
+	bus := New()
+
+	bus.ProvideNamed("named", func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	type deps struct {
+		Get     GetIntService `van:"named,optional"`
+		Set     SetIntService `van:"optional"`
+		Unknown UnknownService
+	}
+
+	if err := bus.validateDependency(typeOf[deps]()); err == nil {
+		t.Fatal("expected the non-optional dependency to fail validation")
+	}
+
+	bus.Provide(func() (UnknownService, error) {
+		return struct{}{}, nil
+	})
+
+	err := bus.Exec(context.Background(), func(d deps) error {
+		if d.Get == nil {
+			t.Fatal("expected the named dependency to be injected")
+		}
+
+		if d.Set != nil {
+			t.Fatal("expected the unregistered dependency to be nil")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptional_NotInterface(t *testing.T) {
+	err := validateDependencyArgs(typeOf[func(Optional[int])](), 0)
+
+	wantErr := "argument 0 must be an optional interface, got van.Optional[int]"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
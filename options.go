@@ -0,0 +1,303 @@
+package van
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// Option configures the bus created with New.
+type Option func(*Van)
+
+// WithClock replaces the function used by the bus to get the current time.
+// It is mostly useful in tests for controlling time-dependent behaviour.
+func WithClock(now func() time.Time) Option {
+	return func(b *Van) {
+		b.now = now
+	}
+}
+
+// WithResolveTimeout limits the time Invoke and Exec may spend on constructing the dependencies.
+// The handler itself is called with the original context, so the timeout does not affect long-running
+// handlers. Providers are expected to respect the cancellation of the context they receive. The context is
+// only cancelled early when the timeout fires, so the providers that finish in time may keep using it until
+// the call is complete.
+func WithResolveTimeout(d time.Duration) Option {
+	return func(b *Van) {
+		b.resolveTimeout = d
+	}
+}
+
+// WithSlowResolveThreshold makes the bus log a warning whenever a provider takes longer than the threshold
+// to construct a dependency. The warning includes the type of the dependency and the time it took.
+func WithSlowResolveThreshold(d time.Duration) Option {
+	return func(b *Van) {
+		b.slowResolveThreshold = d
+	}
+}
+
+// Logger receives the diagnostics of the bus, such as the failures of the event listeners that have
+// no dead letter sink. *log.Logger satisfies the interface.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// WithLogger replaces the standard logger, which the bus uses by default, e.g. to route the diagnostics
+// into a structured logging system. The logger must be safe for concurrent use.
+func WithLogger(l Logger) Option {
+	return func(b *Van) {
+		b.logger = l
+	}
+}
+
+// DependencyInterceptor is called for every dependency constructed by the providers before it is injected.
+// It can return the instance as is, replace it with another value of the same type, or reject it with an error,
+// which aborts the resolution.
+type DependencyInterceptor func(ctx context.Context, t reflect.Type, instance interface{}) (interface{}, error)
+
+// WithDependencyInterceptor sets the interceptor that sees every dependency resolved by the bus,
+// including the nested dependencies of the providers and the fields of dependency structs.
+// It is called on every resolution, including the resolutions of already constructed singletons.
+func WithDependencyInterceptor(interceptor DependencyInterceptor) Option {
+	return func(b *Van) {
+		b.interceptor = interceptor
+	}
+}
+
+// Hooks are the callbacks the bus calls to report the timings of dependency construction and command handling.
+// Since the dependencies are constructed and the commands are handled concurrently, the hooks must be safe
+// for concurrent use. Any of the hooks can be nil.
+type Hooks struct {
+	// OnResolve is called after a provider has constructed a dependency of type t. The duration only
+	// includes the provider call itself, but not the construction of the provider's dependencies.
+	OnResolve func(t reflect.Type, d time.Duration)
+	// OnHandle is called after a command handler has returned. The duration includes the resolution
+	// of the handler's dependencies, but not the middleware.
+	OnHandle func(cmd interface{}, d time.Duration, err error)
+	// OnDuplicateEvent is called for every event dropped by Publish as a duplicate. See WithDedup.
+	OnDuplicateEvent func(event interface{})
+}
+
+// WithHooks sets the hooks for observing the bus, e.g. for collecting metrics.
+func WithHooks(hooks Hooks) Option {
+	return func(b *Van) {
+		b.hooks = hooks
+	}
+}
+
+// Tracer starts the tracing spans around the work done by the bus. The returned context is passed further down,
+// so that the spans started by the handlers, listeners and providers are nested, and the returned function
+// ends the span with the error of the operation, if any. It allows to integrate with tracing libraries,
+// such as OpenTelemetry, without the bus depending on them.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// WithTracer makes the bus start a span for every Invoke call, every listener of a published event, and every
+// dependency constructed by a provider. The spans are named after the operation and the type being processed,
+// e.g. "invoke app.CreateUser", "listener app.UserCreated" or "resolve app.UserRepository".
+// Without a tracer, the bus does not trace anything and does not pay for it.
+func WithTracer(tracer Tracer) Option {
+	return func(b *Van) {
+		b.tracer = tracer
+	}
+}
+
+// WithDeferredValidation allows to register providers, handlers and listeners before their dependencies.
+// The signatures of the functions are still checked on registration, but the presence of the providers for
+// their dependencies is only checked by Validate, which should be called once everything is registered.
+// Without calling Validate, missing dependencies are reported when they are first resolved.
+func WithDeferredValidation() Option {
+	return func(b *Van) {
+		b.deferValidation = true
+	}
+}
+
+// WithPanicHandler replaces the conversion of the recovered panics of the handlers, the listeners and their
+// providers into errors, which produces *PanicError with the panic value and the truncated stack trace by default.
+// It allows to control how much detail ends up in the errors, e.g. to hide the panic value from the callers
+// on the other side of a trust boundary, or to wrap it into an error type of the app. If the handler returns nil,
+// the default *PanicError is used, so a panic is never reported as a success. The handler must be safe for
+// concurrent use. See WithRecover.
+func WithPanicHandler(handler PanicHandler) Option {
+	return func(b *Van) {
+		b.panicHandler = handler
+	}
+}
+
+// UnhandledCommandFunc processes a command that has no handler registered. See WithUnhandledCommand.
+type UnhandledCommandFunc func(ctx context.Context, cmd interface{}) error
+
+// WithUnhandledCommand sets the fallback that Invoke calls with the commands that have no handler registered,
+// instead of returning an error wrapping ErrHandlerNotFound, e.g. to forward them to another service. The result
+// of the fallback is returned from Invoke as is. The command is the pointer passed to Invoke.
+func WithUnhandledCommand(fallback UnhandledCommandFunc) Option {
+	return func(b *Van) {
+		b.unhandledCommand = fallback
+	}
+}
+
+// WithParallelResolve makes the bus construct the dependencies of a handler, listener or provider concurrently,
+// so that the independent branches of the dependency graph, e.g. several singletons doing I/O on their first
+// construction, do not wait for each other. At most the given number of workers run at the same time across
+// the bus, and the dependencies that find no free worker are constructed by the goroutine that needs them.
+// The providers must be safe to call concurrently. A circular dependency of the singletons, which is only
+// possible with WithDeferredValidation, is reported as an error, same as without the parallel mode.
+// A non-positive number of workers leaves the parallel mode off.
+func WithParallelResolve(workers int) Option {
+	return func(b *Van) {
+		if workers <= 0 {
+			b.resolveSlots = nil
+			return
+		}
+
+		b.resolveSlots = make(chan struct{}, workers)
+	}
+}
+
+// WithGlobalListenerLimit limits the number of event listeners executed at the same time across all
+// published events. Listeners that exceed the limit wait for a free slot before being executed.
+// Each published event is processed by a single goroutine that calls its listeners one after another,
+// so the goroutines waiting for a slot are bounded by the number of events in flight, not listeners.
+// Wait also waits for the listeners that are still queued. A non-positive limit means no limit.
+func WithGlobalListenerLimit(n int) Option {
+	return func(b *Van) {
+		if n <= 0 {
+			b.listenerSlots = nil
+			return
+		}
+
+		b.listenerSlots = make(chan struct{}, n)
+	}
+}
+
+// WithGlobalDeadLetter sets the dead letter sink for all listeners that have none set with WithDeadLetter.
+// The sink receives the events that a listener has failed to process, including the recovered panics,
+// along with the error of the last attempt, instead of the failure being logged or returned from PublishSync.
+func WithGlobalDeadLetter(sink DeadLetterFunc) Option {
+	return func(b *Van) {
+		b.deadLetter = sink
+	}
+}
+
+// WithDedup makes Publish drop the events whose key has already been seen within the window since the first
+// event with the same key was published, e.g. to coalesce a burst of identical cache invalidations. The key
+// function receives the event as it is passed to the listeners. The dropped events are reported to the
+// OnDuplicateEvent hook, if there is one. PublishSync never drops events.
+func WithDedup(window time.Duration, key func(event interface{}) string) Option {
+	return func(b *Van) {
+		b.dedupKey = key
+		b.dedupSeen = newTTLSet(window)
+	}
+}
+
+// errStrictBus is reported for the handlers, listeners and providers that depend on *van.Van in strict mode.
+var errStrictBus = errors.New("*van.Van cannot be injected with WithStrictBus, depend on a narrower interface instead")
+
+// WithStrictBus forbids injecting the bus itself as a dependency, so that the handlers, listeners and providers
+// are not coupled to the framework and depend on narrower interfaces instead, e.g. an EventPublisher provided
+// by the app. The functions that take *van.Van are rejected the same way as the ones with missing providers.
+func WithStrictBus() Option {
+	return func(b *Van) {
+		b.strictBus = true
+	}
+}
+
+// WithRecover makes the bus recover the panics of command handlers, which are then returned from Invoke
+// as *PanicError. The panics of event listeners are always recovered and reported the same way as their
+// other failures, so that one bad listener does not crash the whole process.
+func WithRecover() Option {
+	return func(b *Van) {
+		b.recoverPanics = true
+	}
+}
+
+// HandlerOption configures a single command handler registered with Handle.
+type HandlerOption func(*handlerOpts)
+
+// IdempotencyKey defines how the deduplication key of a command is computed. See Idempotent.
+type IdempotencyKey func(*idempotency)
+
+// WithKey makes Idempotent use the string returned by the key function as the deduplication key.
+func WithKey(key func(cmd interface{}) string) IdempotencyKey {
+	return func(i *idempotency) {
+		i.key = func(cmd interface{}) interface{} {
+			return key(cmd)
+		}
+	}
+}
+
+// WithHasher makes Idempotent use a numeric hash of the command as the deduplication key, which is useful
+// for commands that are compared structurally, e.g. by a subset of their fields. Since different commands
+// may produce the same hash, a command with a matching hash is only skipped if it is also deeply equal to
+// the recorded one. In case of a collision, the command is executed and replaces the recorded one, so the
+// previous command is no longer deduplicated.
+func WithHasher(hash func(cmd interface{}) uint64) IdempotencyKey {
+	return func(i *idempotency) {
+		i.key = func(cmd interface{}) interface{} {
+			return hash(cmd)
+		}
+		i.verify = true
+	}
+}
+
+// Idempotent makes the handler skip commands that have already been processed successfully
+// within the ttl. The deduplication key is extracted from the command pointer with either WithKey
+// or WithHasher. A skipped command is not passed to the handler, and Invoke returns nil for it.
+// The key is only recorded after the handler succeeds, so concurrent invocations of the
+// same command may both be executed. Expired keys are evicted as new keys are recorded,
+// so the memory usage is bounded by the number of distinct commands within the ttl.
+func Idempotent(key IdempotencyKey, ttl time.Duration) HandlerOption {
+	return func(h *handlerOpts) {
+		h.idempotency = &idempotency{seen: newTTLSet(ttl)}
+		key(h.idempotency)
+	}
+}
+
+// WithTimeout limits the time the handler may spend on processing a command. See HandleWithTimeout.
+func WithTimeout(d time.Duration) HandlerOption {
+	return func(h *handlerOpts) {
+		h.timeout = d
+	}
+}
+
+// RetryPolicy describes how the handlers registered with HandleWithRetry are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the handler is called, including the first attempt.
+	MaxAttempts int
+	// Backoff returns the delay before the next attempt, given the number of the failed attempt starting from 1.
+	// It may be nil, in which case the handler is retried immediately.
+	Backoff func(attempt int) time.Duration
+	// Retryable reports whether the error returned by the handler is transient and worth retrying.
+	// It may be nil, in which case all errors are retried.
+	Retryable func(err error) bool
+}
+
+// WithRetryPolicy makes the bus retry the handler when it fails. See HandleWithRetry.
+func WithRetryPolicy(policy RetryPolicy) HandlerOption {
+	return func(h *handlerOpts) {
+		h.retry = &policy
+	}
+}
+
+// ProviderOption configures a single provider registered with Provide.
+type ProviderOption func(*providerOpts)
+
+// AutoClose makes the bus close the instances created by the provider once the call they were
+// constructed for is complete. If the instance implements io.Closer, it is closed after the handler
+// returns, regardless of whether it succeeded. Dependencies constructed for a single call are closed
+// in reverse order of construction. Only Invoke, Exec and event listeners track auto-closed instances.
+// Singleton providers can neither be auto-closed nor depend on auto-closed providers.
+func AutoClose() ProviderOption {
+	return func(p *providerOpts) {
+		p.autoClose = true
+	}
+}
+
+// Named registers the provider under the given name. It is the same as registering the provider with ProvideNamed.
+func Named(name string) ProviderOption {
+	return func(p *providerOpts) {
+		p.name = name
+	}
+}
@@ -0,0 +1,998 @@
+package van
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithResolveTimeout(t *testing.T) {
+	bus := New(WithResolveTimeout(10 * time.Millisecond))
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return &GetIntServiceImpl{}, nil
+		}
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		return nil
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithResolveTimeout_SlowHandler(t *testing.T) {
+	bus := New(WithResolveTimeout(10 * time.Millisecond))
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err()
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type ctxService struct {
+	ctx context.Context
+}
+
+func (s *ctxService) Get() int {
+	if s.ctx.Err() != nil {
+		return 0
+	}
+
+	return 1
+}
+
+func TestWithResolveTimeout_ProviderKeepsContext(t *testing.T) {
+	var service *ctxService
+
+	bus := New(WithResolveTimeout(10 * time.Millisecond))
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		service = &ctxService{ctx: ctx}
+		return service, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		if s.Get() != 1 {
+			return fmt.Errorf("provider context is cancelled")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		if s.Get() != 1 {
+			return fmt.Errorf("provider context is cancelled after the timeout")
+		}
+
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if service.Get() != 0 {
+		t.Fatal("expected the provider context to be released once the call is complete")
+	}
+}
+
+func TestWithResolveTimeout_SlowSuccess(t *testing.T) {
+	bus := New(WithResolveTimeout(10 * time.Millisecond))
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		time.Sleep(50 * time.Millisecond)
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type closerService struct {
+	closed int
+}
+
+func (s *closerService) Get() int {
+	return s.closed
+}
+
+func (s *closerService) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestAutoClose(t *testing.T) {
+	var handlerClosed int
+
+	service := &closerService{}
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return service, nil
+	}, AutoClose())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		handlerClosed = s.Get()
+		return errors.New("handler error")
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if handlerClosed != 0 {
+		t.Fatal("expected the dependency to be closed after the handler returns")
+	}
+
+	if service.closed != 1 {
+		t.Fatalf("expected Close to be called once, got %d", service.closed)
+	}
+}
+
+func TestAutoClose_HandlerPanics(t *testing.T) {
+	service := &closerService{}
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return service, nil
+	}, AutoClose())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		panic("handler panic")
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected the panic to propagate")
+			}
+		}()
+
+		_ = bus.Invoke(context.Background(), &Command{})
+	}()
+
+	if service.closed != 1 {
+		t.Fatalf("expected Close to be called once, got %d", service.closed)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+
+		_ = bus.Exec(context.Background(), func(s GetIntService) error {
+			panic("function panic")
+		})
+	}()
+
+	if service.closed != 2 {
+		t.Fatalf("expected Close to be called twice, got %d", service.closed)
+	}
+}
+
+func TestAutoCloseFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "singleton providers cannot be auto-closed", func() {
+		bus.ProvideOnce(func() (GetIntService, error) {
+			return &closerService{}, nil
+		}, AutoClose())
+	})
+
+	bus.Provide(func() (GetIntService, error) {
+		return &closerService{}, nil
+	}, AutoClose())
+
+	panicsWithError(t, "singleton providers cannot depend on auto-closed providers", func() {
+		bus.ProvideOnce(func(s GetIntService) (SetIntService, error) {
+			return &SetIntSevriceImpl{}, nil
+		})
+	})
+}
+
+func TestWithDeferredValidation(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		cmd.Result = s.Get()
+		return nil
+	})
+
+	bus.Provide(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	if err := bus.Validate(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	if err := bus.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected result 1, got %d", cmd.Result)
+	}
+}
+
+func TestWithDeferredValidation_MissingProvider(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		return nil
+	})
+
+	wantErr := "no providers registered for type van.GetIntService"
+
+	if err := bus.Validate(); err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+
+	if err := bus.Invoke(context.Background(), &Command{}); err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestWithDeferredValidation_GroupProvider(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.ProvideGroup(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	wantErr := "no providers registered for type van.SetIntService"
+
+	if err := bus.Validate(); err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestWithDeferredValidation_AllErrors(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s SetIntService) error {
+		return nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, u UnknownService) {})
+
+	bus.Provide(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	wantErr := "no providers registered for type van.SetIntService\nno providers registered for type van.UnknownService"
+
+	for i := 0; i < 10; i++ {
+		if err := bus.Validate(); err == nil || err.Error() != wantErr {
+			t.Fatalf("got %v, want %q", err, wantErr)
+		}
+	}
+}
+
+func TestWithDeferredValidation_SingletonTakesContext(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.ProvideOnce(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Provide(func(ctx context.Context) (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	wantErr := "singleton providers cannot depend on providers that take Context"
+
+	if err := bus.Validate(); err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestWithGlobalListenerLimit(t *testing.T) {
+	const limit = 3
+
+	var maxActive int32
+
+	bus := New(WithGlobalListenerLimit(limit))
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		active := int32(bus.ActiveListeners())
+
+		for {
+			current := atomic.LoadInt32(&maxActive)
+			if active <= current || atomic.CompareAndSwapInt32(&maxActive, current, active) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+	})
+
+	for i := 0; i < 50; i++ {
+		if err := bus.Publish(Event{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus.Wait()
+
+	if maxActive > limit {
+		t.Fatalf("expected at most %d active listeners, got %d", limit, maxActive)
+	}
+
+	if bus.ActiveListeners() != 0 {
+		t.Fatalf("expected no active listeners, got %d", bus.ActiveListeners())
+	}
+}
+
+func TestWithGlobalListenerLimit_NonPositive(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		var calls int32
+
+		bus := New(WithGlobalListenerLimit(limit), WithParallelResolve(limit))
+
+		bus.Provide(func() (GetIntService, error) {
+			return &GetIntServiceImpl{}, nil
+		})
+
+		bus.Provide(func() (SetIntService, error) {
+			return &SetIntSevriceImpl{}, nil
+		})
+
+		bus.Subscribe(Event{}, func(ctx context.Context, event Event, get GetIntService, set SetIntService) {
+			atomic.AddInt32(&calls, 1)
+		})
+
+		if err := bus.Publish(Event{}); err != nil {
+			t.Fatal(err)
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			bus.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("listener blocked with the limit of %d", limit)
+		}
+
+		if atomic.LoadInt32(&calls) != 1 {
+			t.Fatalf("expected the listener to be called once with the limit of %d, got %d", limit, calls)
+		}
+	}
+}
+
+func TestWithSlowResolveThreshold(t *testing.T) {
+	var buf bytes.Buffer
+
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	bus := New(WithSlowResolveThreshold(time.Millisecond))
+
+	bus.Provide(func() (GetIntService, error) {
+		time.Sleep(5 * time.Millisecond)
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	err := bus.Exec(context.Background(), func(GetIntService, SetIntService) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+
+	if !strings.Contains(output, "van: slow dependency resolution: type=van.GetIntService") {
+		t.Fatalf("expected a warning about GetIntService, got %q", output)
+	}
+
+	if strings.Contains(output, "van.SetIntService") {
+		t.Fatalf("unexpected warning about SetIntService, got %q", output)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	bus := New(WithDeferredValidation(), WithLogger(log.New(&buf, "", 0)))
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, svc UnknownService) {})
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	want := "van: failed to resolve dependencies for func(context.Context, van.Event, van.UnknownService): " +
+		"no providers registered for type van.UnknownService\n"
+
+	if output := buf.String(); output != want {
+		t.Fatalf("got %q, want %q", output, want)
+	}
+}
+
+type wrappedGetIntService struct {
+	GetIntService
+}
+
+func (s *wrappedGetIntService) Get() int {
+	return s.GetIntService.Get() + 1
+}
+
+func TestWithDependencyInterceptor(t *testing.T) {
+	var seen []reflect.Type
+
+	interceptor := func(ctx context.Context, t reflect.Type, instance interface{}) (interface{}, error) {
+		seen = append(seen, t)
+
+		if s, ok := instance.(GetIntService); ok {
+			return &wrappedGetIntService{s}, nil
+		}
+
+		return instance, nil
+	}
+
+	bus := New(WithDependencyInterceptor(interceptor))
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.ProvideOnce(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	var got int
+
+	err := bus.Exec(context.Background(), func(s GetIntService) error {
+		got = s.Get()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != 2 {
+		t.Fatalf("expected the intercepted service to return 2, got %d", got)
+	}
+
+	want := []reflect.Type{
+		reflect.TypeOf((*SetIntService)(nil)).Elem(),
+		reflect.TypeOf((*GetIntService)(nil)).Elem(),
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Fatalf("got %v, want %v", seen, want)
+	}
+}
+
+func TestWithDependencyInterceptor_Reject(t *testing.T) {
+	var handlerExecuted int
+
+	wantErr := errors.New("forbidden")
+
+	bus := New(WithDependencyInterceptor(func(ctx context.Context, t reflect.Type, instance interface{}) (interface{}, error) {
+		return nil, wantErr
+	}))
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		handlerExecuted++
+		return nil
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+
+	if handlerExecuted != 0 {
+		t.Fatalf("handlerExecuted != 0, got %d", handlerExecuted)
+	}
+}
+
+func TestWithRecover(t *testing.T) {
+	bus := New(WithRecover())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		panic("boom")
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+
+	if panicErr.Error() != "handler of van.Command panicked: boom" {
+		t.Fatalf("unexpected error: %v", panicErr)
+	}
+
+	if len(panicErr.Stack) == 0 {
+		t.Fatal("expected the stack trace to be captured")
+	}
+}
+
+func TestWithRecover_Listener(t *testing.T) {
+	bus := New(WithRecover())
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		panic("boom")
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, scope *Scope) error {
+		return scope.Publish(Event{})
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if err == nil || err.Error() != "listener van.TestWithRecover_Listener.func1 of van.Event panicked: boom" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithHooks(t *testing.T) {
+	var (
+		resolved []reflect.Type
+		handled  []interface{}
+		handlErr error
+	)
+
+	wantErr := errors.New("failed")
+
+	bus := New(WithHooks(Hooks{
+		OnResolve: func(t reflect.Type, d time.Duration) {
+			resolved = append(resolved, t)
+		},
+		OnHandle: func(cmd interface{}, d time.Duration, err error) {
+			handled = append(handled, cmd)
+			handlErr = err
+		},
+	}))
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		return wantErr
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+
+	if len(resolved) != 1 || resolved[0] != typeOf[GetIntService]() {
+		t.Fatalf("unexpected resolved types: %v", resolved)
+	}
+
+	if len(handled) != 1 || handled[0] != cmd || handlErr != wantErr {
+		t.Fatalf("unexpected handled commands: %v, %v", handled, handlErr)
+	}
+}
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	spans []string
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		name = parent + " > " + name
+	}
+
+	t.spans = append(t.spans, "start "+name)
+
+	return context.WithValue(ctx, spanKey{}, name), func(err error) {
+		t.spans = append(t.spans, fmt.Sprintf("end %s: %v", name, err))
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	bus := New(WithTracer(tracer))
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		return nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start invoke van.Command",
+		"start invoke van.Command > resolve van.GetIntService",
+		"end invoke van.Command > resolve van.GetIntService: <nil>",
+		"end invoke van.Command: <nil>",
+		"start listener van.Event",
+		"end listener van.Event: <nil>",
+	}
+
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Fatalf("got %v, want %v", tracer.spans, want)
+	}
+}
+
+func TestWithStrictBus(t *testing.T) {
+	bus := New(WithStrictBus())
+
+	panicsWithError(t, errStrictBus.Error(), func() {
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command, b *Van) error {
+			return nil
+		})
+	})
+
+	panicsWithError(t, errStrictBus.Error(), func() {
+		bus.Subscribe(Event{}, func(ctx context.Context, event Event, deps struct{ Bus *Van }) {})
+	})
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s GetIntService) error {
+		cmd.Result = s.Get()
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithStrictBus_DeferredValidation(t *testing.T) {
+	bus := New(WithStrictBus(), WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, b *Van) error {
+		return nil
+	})
+
+	if err := bus.Validate(); !errors.Is(err, errStrictBus) {
+		t.Fatalf("got %v, want %v", err, errStrictBus)
+	}
+
+	if err := bus.ValidateGraph(); !errors.Is(err, errStrictBus) {
+		t.Fatalf("got %v, want %v", err, errStrictBus)
+	}
+
+	if err := bus.Invoke(context.Background(), &Command{}); !errors.Is(err, errStrictBus) {
+		t.Fatalf("got %v, want %v", err, errStrictBus)
+	}
+}
+
+func TestWithDedup(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	var received, dropped int32
+
+	bus := New(
+		WithClock(func() time.Time { return now }),
+		WithDedup(time.Second, func(event interface{}) string {
+			return fmt.Sprint(event.(Event).Value)
+		}),
+		WithHooks(Hooks{
+			OnDuplicateEvent: func(event interface{}) {
+				atomic.AddInt32(&dropped, 1)
+			},
+		}),
+	)
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		atomic.AddInt32(&received, 1)
+	})
+
+	for _, value := range []int{1, 1, 2, 1} {
+		if err := bus.Publish(Event{Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus.Wait()
+
+	if received != 2 || dropped != 2 {
+		t.Fatalf("expected 2 events received and 2 dropped, got %d and %d", received, dropped)
+	}
+
+	now = now.Add(time.Second)
+
+	if err := bus.Publish(Event{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if received != 3 {
+		t.Fatalf("expected the event to be received once the window has passed, got %d", received)
+	}
+}
+
+func TestWithParallelResolve(t *testing.T) {
+	var sharedCalls int32
+
+	started := make(chan struct{}, 2)
+
+	// waitBoth blocks until both providers have started, which only happens if they run concurrently
+	waitBoth := func() error {
+		started <- struct{}{}
+
+		deadline := time.After(time.Second)
+		for len(started) < 2 {
+			select {
+			case <-deadline:
+				return fmt.Errorf("providers are not constructed concurrently")
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		return nil
+	}
+
+	bus := New(WithParallelResolve(2))
+
+	bus.ProvideOnce(func() (*getSetIntService, error) {
+		atomic.AddInt32(&sharedCalls, 1)
+		return &getSetIntService{value: 1}, nil
+	})
+
+	bus.ProvideOnce(func(s *getSetIntService) (GetIntService, error) {
+		return s, waitBoth()
+	})
+
+	bus.ProvideOnce(func(s *getSetIntService) (SetIntService, error) {
+		return s, waitBoth()
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService, set SetIntService) error {
+		set.Set(42)
+		cmd.Result = get.Get()
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 42 {
+		t.Fatalf("expected result 42, got %d", cmd.Result)
+	}
+
+	if sharedCalls != 1 {
+		t.Fatalf("expected the shared singleton to be constructed once, got %d", sharedCalls)
+	}
+}
+
+func TestWithParallelResolve_Error(t *testing.T) {
+	bus := New(WithParallelResolve(1))
+
+	bus.Provide(func() (GetIntService, error) {
+		return nil, fmt.Errorf("failed")
+	})
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, set SetIntService, get GetIntService) error {
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWithParallelResolve_CircularDependency(t *testing.T) {
+	bus := New(WithDeferredValidation(), WithParallelResolve(2))
+
+	started := make(chan struct{}, 2)
+
+	// the barrier makes each goroutine hold one of the singletons before requesting the other one
+	bus.Provide(func() (UnknownService, error) {
+		started <- struct{}{}
+
+		deadline := time.After(time.Second)
+		for len(started) < 2 {
+			select {
+			case <-deadline:
+				return nil, fmt.Errorf("singletons are not constructed concurrently")
+			default:
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		return struct{}{}, nil
+	})
+
+	type getDeps struct {
+		Barrier UnknownService
+		Set     SetIntService
+	}
+
+	type setDeps struct {
+		Barrier UnknownService
+		Get     GetIntService
+	}
+
+	bus.ProvideOnce(func(d getDeps) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideOnce(func(d setDeps) (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- bus.Exec(context.Background(), func(g GetIntService, s SetIntService) error {
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "circular singleton dependency") {
+			t.Fatalf("expected a circular dependency error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("singleton construction deadlocked")
+	}
+}
+
+func TestWithPanicHandler(t *testing.T) {
+	errInternal := errors.New("internal error")
+
+	var recovered []interface{}
+
+	bus := New(WithRecover(), WithPanicHandler(func(r interface{}) error {
+		recovered = append(recovered, r)
+		return errInternal
+	}))
+
+	bus.Provide(func() (GetIntService, error) {
+		panic("provider")
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		panic("handler")
+	})
+
+	var listenerErr error
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, get GetIntService) {},
+		WithDeadLetter(func(ctx context.Context, event interface{}, err error) {
+			listenerErr = err
+		}),
+	)
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != errInternal {
+		t.Fatalf("got %v, want %v", err, errInternal)
+	}
+
+	if err := bus.PublishSync(context.Background(), Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if listenerErr != errInternal {
+		t.Fatalf("got %v, want %v", listenerErr, errInternal)
+	}
+
+	if !reflect.DeepEqual(recovered, []interface{}{"handler", "provider"}) {
+		t.Fatalf("unexpected recovered values: %v", recovered)
+	}
+}
+
+func TestWithPanicHandler_ReturnsNil(t *testing.T) {
+	bus := New(WithRecover(), WithPanicHandler(func(r interface{}) error {
+		return nil
+	}))
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		panic("handler")
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "handler" {
+		t.Fatalf("expected the default *PanicError, got %v", err)
+	}
+}
+
+func TestWithUnhandledCommand(t *testing.T) {
+	type UnknownCommand struct {
+		Name string
+	}
+
+	errForwarded := errors.New("forwarded")
+
+	var forwarded interface{}
+
+	bus := New(WithUnhandledCommand(func(ctx context.Context, cmd interface{}) error {
+		forwarded = cmd
+		return errForwarded
+	}))
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		cmd.Result = 1
+		return nil
+	})
+
+	cmd := &UnknownCommand{Name: "a"}
+	if err := bus.Invoke(context.Background(), cmd); err != errForwarded {
+		t.Fatalf("got %v, want %v", err, errForwarded)
+	}
+
+	if forwarded != cmd {
+		t.Fatalf("expected the command to be passed to the fallback, got %v", forwarded)
+	}
+
+	handled := &Command{}
+	if err := bus.Invoke(context.Background(), handled); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled.Result != 1 {
+		t.Fatal("expected the registered handler to be called")
+	}
+}
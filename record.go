@@ -0,0 +1,53 @@
+package van
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// recorder captures the commands and events while the bus is in the recording mode.
+type recorder struct {
+	recording atomic.Bool // checked before taking the lock, so that dispatching is not slowed down
+
+	mu       sync.Mutex
+	messages []interface{}
+}
+
+// record stores the message if the recording mode is on, reporting whether it did.
+func (r *recorder) record(msg interface{}) bool {
+	if !r.recording.Load() {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, msg)
+
+	return true
+}
+
+// Record switches the bus into the recording mode, meant for unit tests of the code that dispatches commands
+// and events. While recording, Invoke, Publish and PublishSync store the command or event and return nil,
+// without resolving dependencies or calling handlers and listeners. Previously recorded messages are discarded.
+// The returned function switches the recording mode off, keeping the recorded messages available.
+func (b *Van) Record() (stop func()) {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+
+	b.recorder.messages = nil
+	b.recorder.recording.Store(true)
+
+	return func() {
+		b.recorder.recording.Store(false)
+	}
+}
+
+// Recorded returns the commands and events captured in the recording mode, in the order they were dispatched.
+// Commands are recorded as the pointers passed to Invoke. The returned slice is a copy.
+func (b *Van) Recorded() []interface{} {
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+
+	return append([]interface{}(nil), b.recorder.messages...)
+}
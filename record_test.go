@@ -0,0 +1,65 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	var handled, listened int
+
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		handled++
+		return nil
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		listened++
+	})
+
+	stop := bus.Record()
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Publish(Event{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishSync(context.Background(), Event{Value: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown commands are recorded as well, since the handlers are never looked up
+	if err := bus.Invoke(context.Background(), &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stop()
+
+	if err := bus.PublishSync(context.Background(), Event{Value: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if handled != 0 || listened != 1 {
+		t.Fatalf("expected only the event published after stop to be processed, got %d handled, %d listened", handled, listened)
+	}
+
+	want := []interface{}{cmd, Event{Value: 1}, Event{Value: 2}, &struct{}{}}
+	if got := bus.Recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	bus.Record()
+
+	if got := bus.Recorded(); len(got) != 0 {
+		t.Fatalf("expected the recorded messages to be discarded, got %v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package van
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicError is returned in place of a panic recovered from a command handler or an event listener.
+type PanicError struct {
+	// Source is either "handler" or "listener".
+	Source string
+	// Type is the type of the command or the event being processed.
+	Type reflect.Type
+	// Listener is the name of the listener function that panicked. It is empty for handlers.
+	Listener string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the moment of the panic, truncated to maxPanicStack bytes.
+	Stack []byte
+}
+
+// maxPanicStack is the maximum size of the stack trace captured by PanicError.
+const maxPanicStack = 8 << 10
+
+// PanicHandler converts the value recovered from a panic into the error returned in its place. See WithPanicHandler.
+type PanicHandler func(recovered interface{}) error
+
+func newPanicError(source string, t reflect.Type, listener string, value interface{}) *PanicError {
+	stack := debug.Stack()
+	if len(stack) > maxPanicStack {
+		stack = stack[:maxPanicStack]
+	}
+
+	return &PanicError{
+		Source:   source,
+		Type:     t,
+		Listener: listener,
+		Value:    value,
+		Stack:    stack,
+	}
+}
+
+// panicError converts the recovered panic into an error with the panic handler of the bus,
+// falling back to *PanicError if there is none, or if the handler returns nil.
+func (b *Van) panicError(value interface{}, source string, t reflect.Type, listener string) error {
+	if b.panicHandler != nil {
+		if err := b.panicHandler(value); err != nil {
+			return err
+		}
+	}
+
+	return newPanicError(source, t, listener, value)
+}
+
+func (e *PanicError) Error() string {
+	if e.Listener != "" {
+		return fmt.Sprintf("%s %s of %s panicked: %v", e.Source, e.Listener, e.Type.String(), e.Value)
+	}
+
+	return fmt.Sprintf("%s of %s panicked: %v", e.Source, e.Type.String(), e.Value)
+}
+
+// funcName returns the name of the function qualified with the name of its package, e.g. app.SendEmail.
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+
+	return name[strings.LastIndex(name, "/")+1:]
+}
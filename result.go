@@ -0,0 +1,81 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+type resultKey struct{}
+
+// result is a slot for the value returned by a query handler, passed down to the handler through the context,
+// so that it survives the middleware chain without changing the signature of InvokeFunc.
+type result struct {
+	value interface{}
+}
+
+func withResult(ctx context.Context, r *result) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+func resultFromContext(ctx context.Context) *result {
+	r, _ := ctx.Value(resultKey{}).(*result)
+	return r
+}
+
+// InvokeResult runs an associated command handler, same as Invoke, and returns the value produced by the handler.
+// Query handlers have the signature of func(ctx, *Cmd, deps...) (R, error). For handlers that only return
+// an error, as well as for commands skipped as duplicates, the returned value is nil. The value is also nil
+// whenever the handler fails.
+func (b *Van) InvokeResult(ctx context.Context, cmd interface{}) (interface{}, error) {
+	r := &result{}
+
+	if err := b.dispatch(withResult(ctx, r), cmd); err != nil {
+		return nil, err
+	}
+
+	return r.value, nil
+}
+
+// Query is a typed version of InvokeResult. It fails if the handler returns a value that is not assignable to R.
+func Query[R any](ctx context.Context, b *Van, cmd interface{}) (R, error) {
+	var zero R
+
+	value, err := b.InvokeResult(ctx, cmd)
+	if err != nil {
+		return zero, err
+	}
+
+	if value == nil {
+		return zero, nil
+	}
+
+	res, ok := value.(R)
+	if !ok {
+		return zero, fmt.Errorf("handler returned %T, which is not assignable to %s", value, reflect.TypeOf(&zero).Elem().String())
+	}
+
+	return res, nil
+}
+
+// ExecValue executes the given function inside the dependency injector, same as Exec, and returns the value
+// produced by the function. The function has the signature of func(deps...) (T, error). The zero value of T
+// is returned if the dependencies cannot be resolved or the function fails.
+func ExecValue[T any](ctx context.Context, b *Van, fn interface{}) (T, error) {
+	var zero T
+
+	funcType := reflect.TypeOf(fn)
+
+	if err := validateExecValueSignature(funcType, reflect.TypeOf(&zero).Elem()); err != nil {
+		return zero, err
+	}
+
+	ret, err := b.exec(ctx, fn, funcType)
+	if err != nil {
+		return zero, err
+	}
+
+	value, _ := ret[0].Interface().(T)
+
+	return value, nil
+}
@@ -0,0 +1,195 @@
+package van
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type SumQuery struct {
+	A, B int
+}
+
+func TestInvokeResult(t *testing.T) {
+	bus := New()
+	bus.Handle(SumQuery{}, func(ctx context.Context, q *SumQuery) (int, error) {
+		return q.A + q.B, nil
+	})
+
+	value, err := bus.InvokeResult(context.Background(), &SumQuery{A: 1, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 3 {
+		t.Fatalf("expected 3, got %v", value)
+	}
+
+	// the result is discarded by Invoke
+	if err := bus.Invoke(context.Background(), &SumQuery{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvokeResult_Error(t *testing.T) {
+	wantErr := errors.New("failed")
+
+	bus := New()
+	bus.Handle(SumQuery{}, func(ctx context.Context, q *SumQuery) (int, error) {
+		return 1, wantErr
+	})
+
+	value, err := bus.InvokeResult(context.Background(), &SumQuery{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+
+	if value != nil {
+		t.Fatalf("expected nil value, got %v", value)
+	}
+}
+
+func TestInvokeResult_CommandHandler(t *testing.T) {
+	bus := New()
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		return nil
+	})
+
+	value, err := bus.InvokeResult(context.Background(), &Command{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != nil {
+		t.Fatalf("expected nil value, got %v", value)
+	}
+}
+
+func TestInvokeResult_Nested(t *testing.T) {
+	bus := New()
+	bus.Handle(SumQuery{}, func(ctx context.Context, q *SumQuery) (int, error) {
+		return q.A + q.B, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		if err := bus.Invoke(ctx, &SumQuery{A: 40, B: 2}); err != nil {
+			return err
+		}
+
+		sum, err := Query[int](ctx, bus, &SumQuery{A: 1, B: 2})
+		cmd.Result = sum
+
+		return err
+	})
+
+	cmd := &Command{}
+
+	value, err := bus.InvokeResult(context.Background(), cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != nil {
+		t.Fatalf("expected nil value of the outer handler, got %v", value)
+	}
+
+	if cmd.Result != 3 {
+		t.Fatalf("expected the inner query to return 3, got %d", cmd.Result)
+	}
+}
+
+func TestInvokeResult_Middleware(t *testing.T) {
+	var called bool
+
+	mw := func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, cmd interface{}) error {
+			called = true
+			return next(ctx, cmd)
+		}
+	}
+
+	bus := New()
+	bus.Handle(SumQuery{}, func(ctx context.Context, q *SumQuery) (int, error) {
+		return q.A + q.B, nil
+	}, WithMiddleware(mw))
+
+	value, err := Query[int](context.Background(), bus, &SumQuery{A: 2, B: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !called {
+		t.Fatal("middleware was not called")
+	}
+
+	if value != 4 {
+		t.Fatalf("expected 4, got %d", value)
+	}
+}
+
+func TestQuery_TypeMismatch(t *testing.T) {
+	bus := New()
+	bus.Handle(SumQuery{}, func(ctx context.Context, q *SumQuery) (int, error) {
+		return 0, nil
+	})
+
+	_, err := Query[string](context.Background(), bus, &SumQuery{})
+
+	wantErr := "handler returned int, which is not assignable to string"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected %q, got %v", wantErr, err)
+	}
+}
+
+func TestExecValue(t *testing.T) {
+	bus := New()
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	value, err := ExecValue[int](context.Background(), bus, func(get GetIntService) (int, error) {
+		return get.Get() + 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 2 {
+		t.Fatalf("expected 2, got %v", value)
+	}
+
+	wantErr := errors.New("failed")
+
+	value, err = ExecValue[int](context.Background(), bus, func() (int, error) {
+		return 1, wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+
+	if value != 0 {
+		t.Fatalf("expected the zero value on error, got %v", value)
+	}
+}
+
+func TestExecValue_Fails(t *testing.T) {
+	bus := New()
+
+	_, err := ExecValue[string](context.Background(), bus, func() (int, error) {
+		return 0, nil
+	})
+
+	wantErr := "first return value must be assignable to string, got int"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected %q, got %v", wantErr, err)
+	}
+
+	_, err = ExecValue[int](context.Background(), bus, func(get GetIntService) (int, error) {
+		return 0, nil
+	})
+
+	wantErr = "no providers registered for type van.GetIntService"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected %q, got %v", wantErr, err)
+	}
+}
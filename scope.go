@@ -0,0 +1,70 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+type scopeKey struct{}
+
+// Scope is a structured concurrency token that can be injected into command handlers as *van.Scope.
+// Events published through the scope are processed in the background just like regular events,
+// but the Invoke call does not return until all of them are processed. The first failure of any
+// listener is returned from Invoke, unless the handler itself has returned an error.
+type Scope struct {
+	bus  *Van
+	ctx  context.Context
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+func newScope(ctx context.Context, bus *Van) *Scope {
+	return &Scope{bus: bus, ctx: ctx}
+}
+
+// Publish sends an event to the bus, same as Van.Publish, but attaches its processing to the scope.
+// The listeners receive the context of the handler, so they are cancelled together with the command.
+func (s *Scope) Publish(event interface{}) error {
+	return s.bus.publish(s.ctx, event, s.report, &s.wg)
+}
+
+func (s *Scope) report(err error) {
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.mu.Unlock()
+}
+
+// wait blocks until all events published through the scope are processed and returns the first error.
+func (s *Scope) wait() error {
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errs) > 0 {
+		return s.errs[0]
+	}
+
+	return nil
+}
+
+func withScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+func scopeFromContext(ctx context.Context) *Scope {
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
+
+func takesScope(t reflect.Type) bool {
+	for i := 0; i < t.NumIn(); i++ {
+		if t.In(i) == typeScope {
+			return true
+		}
+	}
+
+	return false
+}
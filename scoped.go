@@ -0,0 +1,74 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+type (
+	scopedCacheKey struct{}
+	structCacheKey struct{}
+)
+
+// scopedCache holds the instances of the scoped providers constructed during a single call, or the instances
+// of the transient providers constructed while building a single dependency struct.
+// All methods are safe to call on a nil receiver, which is used outside of a call.
+type scopedCache struct {
+	mu        sync.Mutex
+	instances map[*providerOpts]reflect.Value
+}
+
+func withScopedCache(ctx context.Context, c *scopedCache) context.Context {
+	return context.WithValue(ctx, scopedCacheKey{}, c)
+}
+
+func scopedCacheFromContext(ctx context.Context) *scopedCache {
+	c, _ := ctx.Value(scopedCacheKey{}).(*scopedCache)
+	return c
+}
+
+func withStructCache(ctx context.Context, c *scopedCache) context.Context {
+	return context.WithValue(ctx, structCacheKey{}, c)
+}
+
+func structCacheFromContext(ctx context.Context) *scopedCache {
+	c, _ := ctx.Value(structCacheKey{}).(*scopedCache)
+	return c
+}
+
+func (c *scopedCache) get(p *providerOpts) (reflect.Value, bool) {
+	if c == nil {
+		return reflect.Value{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	inst, ok := c.instances[p]
+
+	return inst, ok
+}
+
+// add stores the instance constructed by the provider, unless another instance has been stored
+// concurrently, in which case the stored one is returned, so that the call sees a single instance.
+func (c *scopedCache) add(p *providerOpts, inst reflect.Value) reflect.Value {
+	if c == nil {
+		return inst
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.instances[p]; ok {
+		return existing
+	}
+
+	if c.instances == nil {
+		c.instances = make(map[*providerOpts]reflect.Value)
+	}
+
+	c.instances[p] = inst
+
+	return inst
+}
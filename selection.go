@@ -0,0 +1,43 @@
+package van
+
+import (
+	"context"
+	"reflect"
+)
+
+type selectionKey struct{}
+
+// WithNamedSelection returns a copy of the context that selects the named providers used for the interface
+// dependencies resolved under it, so that the middleware can route a single call among the named implementations
+// without changing the handlers. When a plain interface dependency is requested and the selection contains
+// its type, the provider registered under the selected name is used instead of the default one. Explicit
+// names given with the `van:"name"` tag take precedence over the selection. Selections of the nested
+// contexts are merged, with the innermost one winning for the same type.
+func WithNamedSelection(ctx context.Context, selection map[reflect.Type]string) context.Context {
+	parent := selectionFromContext(ctx)
+	merged := make(map[reflect.Type]string, len(parent)+len(selection))
+
+	for t, name := range parent {
+		merged[t] = name
+	}
+
+	for t, name := range selection {
+		merged[t] = name
+	}
+
+	return context.WithValue(ctx, selectionKey{}, merged)
+}
+
+func selectionFromContext(ctx context.Context) map[reflect.Type]string {
+	s, _ := ctx.Value(selectionKey{}).(map[reflect.Type]string)
+	return s
+}
+
+// selectedName returns the name of the provider selected for the interface type t, or an empty string.
+func selectedName(ctx context.Context, t reflect.Type) string {
+	if t.Kind() != reflect.Interface {
+		return ""
+	}
+
+	return selectionFromContext(ctx)[t]
+}
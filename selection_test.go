@@ -0,0 +1,143 @@
+package van
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type Storage interface {
+	Region() string
+}
+
+type regionStorage string
+
+func (s regionStorage) Region() string {
+	return string(s)
+}
+
+type StoreCommand struct {
+	Region string
+}
+
+var (
+	typeStorage       = reflect.TypeOf((*Storage)(nil)).Elem()
+	typeGetIntService = reflect.TypeOf((*GetIntService)(nil)).Elem()
+)
+
+func newRegionBus() *Van {
+	bus := New()
+
+	bus.Provide(func() (Storage, error) {
+		return regionStorage("default"), nil
+	})
+
+	bus.ProvideNamed("eu", func() (Storage, error) {
+		return regionStorage("eu"), nil
+	})
+
+	bus.ProvideNamed("us", func() (Storage, error) {
+		return regionStorage("us"), nil
+	})
+
+	return bus
+}
+
+func TestWithNamedSelection(t *testing.T) {
+	bus := newRegionBus()
+
+	type deps struct {
+		Storage Storage
+	}
+
+	bus.Handle(StoreCommand{}, func(ctx context.Context, cmd *StoreCommand, s Storage, d deps) error {
+		if s.Region() != cmd.Region || d.Storage.Region() != cmd.Region {
+			t.Errorf("expected %s storage, got %s and %s", cmd.Region, s.Region(), d.Storage.Region())
+		}
+
+		return nil
+	})
+
+	tests := map[string]struct {
+		selection map[reflect.Type]string
+		region    string
+	}{
+		"NoSelection": {
+			selection: nil,
+			region:    "default",
+		},
+		"SelectEU": {
+			selection: map[reflect.Type]string{typeStorage: "eu"},
+			region:    "eu",
+		},
+		"SelectUS": {
+			selection: map[reflect.Type]string{typeStorage: "us"},
+			region:    "us",
+		},
+		"OtherType": {
+			selection: map[reflect.Type]string{typeGetIntService: "eu"},
+			region:    "default",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := WithNamedSelection(context.Background(), tt.selection)
+
+			if err := bus.Invoke(ctx, &StoreCommand{Region: tt.region}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWithNamedSelection_TagWins(t *testing.T) {
+	bus := newRegionBus()
+
+	type deps struct {
+		Selected Storage
+		Tagged   Storage `van:"us"`
+	}
+
+	ctx := WithNamedSelection(context.Background(), map[reflect.Type]string{typeStorage: "eu"})
+
+	err := bus.Exec(ctx, func(d deps) error {
+		if d.Selected.Region() != "eu" {
+			t.Errorf("expected eu storage, got %s", d.Selected.Region())
+		}
+
+		if d.Tagged.Region() != "us" {
+			t.Errorf("expected us storage, got %s", d.Tagged.Region())
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithNamedSelection_Nested(t *testing.T) {
+	ctx := WithNamedSelection(context.Background(), map[reflect.Type]string{typeStorage: "eu", typeGetIntService: "one"})
+	ctx = WithNamedSelection(ctx, map[reflect.Type]string{typeStorage: "us"})
+
+	want := map[reflect.Type]string{typeStorage: "us", typeGetIntService: "one"}
+	if got := selectionFromContext(ctx); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestWithNamedSelection_NotFound(t *testing.T) {
+	bus := newRegionBus()
+
+	ctx := WithNamedSelection(context.Background(), map[reflect.Type]string{typeStorage: "asia"})
+
+	err := bus.Exec(ctx, func(s Storage) error {
+		return nil
+	})
+
+	wantErr := `no providers registered for type van.Storage named "asia"`
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
@@ -0,0 +1,109 @@
+package van
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+type idempotency struct {
+	key    func(cmd interface{}) interface{}
+	verify bool
+	seen   *ttlSet
+}
+
+// entry returns the key of the command in the seen-set, and the value to compare the command with
+// in case the keys may collide.
+func (i *idempotency) entry(cmd interface{}) (key, value interface{}) {
+	key = i.key(cmd)
+
+	if i.verify {
+		value = reflect.ValueOf(cmd).Elem().Interface()
+	}
+
+	return key, value
+}
+
+func (i *idempotency) isDuplicate(key, value interface{}, now time.Time) bool {
+	stored, ok := i.seen.get(key, now)
+
+	return ok && (!i.verify || reflect.DeepEqual(stored, value))
+}
+
+type ttlEntry struct {
+	key     interface{}
+	value   interface{}
+	expires time.Time
+}
+
+// ttlSet is a set of keys that expire after a fixed ttl. Since the ttl is the same for
+// all keys, the insertion order is also the expiration order, which allows to evict
+// expired keys from the head of the queue without scanning the whole set.
+type ttlSet struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[interface{}]ttlEntry
+	queue   []ttlEntry
+}
+
+func newTTLSet(ttl time.Duration) *ttlSet {
+	return &ttlSet{
+		ttl:     ttl,
+		entries: make(map[interface{}]ttlEntry),
+	}
+}
+
+// get returns the value stored along with the key, if the key has not expired yet.
+func (s *ttlSet) get(key interface{}, now time.Time) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+func (s *ttlSet) add(key, value interface{}, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(now)
+
+	entry := ttlEntry{key: key, value: value, expires: now.Add(s.ttl)}
+	s.entries[key] = entry
+	s.queue = append(s.queue, entry)
+}
+
+// addIfAbsent adds the key unless it is already in the set and has not expired yet, and reports whether it did.
+// Unlike get followed by add, it is atomic, so only one of the concurrent callers adds the key.
+func (s *ttlSet) addIfAbsent(key, value interface{}, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(now)
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		return false
+	}
+
+	entry := ttlEntry{key: key, value: value, expires: now.Add(s.ttl)}
+	s.entries[key] = entry
+	s.queue = append(s.queue, entry)
+
+	return true
+}
+
+func (s *ttlSet) evict(now time.Time) {
+	for len(s.queue) > 0 && !now.Before(s.queue[0].expires) {
+		entry := s.queue[0]
+		s.queue = s.queue[1:]
+
+		// the key might have been re-added later with a new expiration time
+		if s.entries[entry.key].expires.Equal(entry.expires) {
+			delete(s.entries, entry.key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package van
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLSet(t *testing.T) {
+	now := time.Unix(0, 0)
+	set := newTTLSet(time.Second)
+
+	set.add("a", 1, now)
+
+	if value, ok := set.get("a", now); !ok || value != 1 {
+		t.Fatalf("expected a to be in the set with value 1, got %v", value)
+	}
+
+	if _, ok := set.get("b", now); ok {
+		t.Fatal("expected b not to be in the set")
+	}
+
+	now = now.Add(time.Second)
+
+	if _, ok := set.get("a", now); ok {
+		t.Fatal("expected a to expire")
+	}
+
+	set.add("b", nil, now)
+
+	if len(set.entries) != 1 || len(set.queue) != 1 {
+		t.Fatalf("expected expired keys to be evicted, got %d keys", len(set.entries))
+	}
+}
+
+func TestTTLSet_ReAdd(t *testing.T) {
+	now := time.Unix(0, 0)
+	set := newTTLSet(time.Second)
+
+	set.add("a", nil, now)
+	set.add("a", nil, now.Add(500*time.Millisecond))
+
+	// the first entry expires, but the key was re-added later
+	now = now.Add(time.Second)
+	set.add("b", nil, now)
+
+	if _, ok := set.get("a", now); !ok {
+		t.Fatal("expected a to be in the set")
+	}
+}
+
+func TestTTLSet_AddIfAbsent(t *testing.T) {
+	now := time.Unix(0, 0)
+	set := newTTLSet(time.Second)
+
+	if !set.addIfAbsent("a", nil, now) {
+		t.Fatal("expected a to be added")
+	}
+
+	if set.addIfAbsent("a", nil, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected a not to be added again")
+	}
+
+	if !set.addIfAbsent("a", nil, now.Add(time.Second)) {
+		t.Fatal("expected a to be added once expired")
+	}
+}
@@ -0,0 +1,154 @@
+package van
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Resolve returns a single dependency of type T constructed through the registered providers.
+// The provider errors are wrapped the same way as during the regular resolution, and singleton
+// providers return the cached instance on subsequent calls.
+func Resolve[T any](ctx context.Context, b *Van) (T, error) {
+	var zero T
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t == typeVan || t == typePublisher || t == typeDispatcher {
+		return any(b).(T), nil
+	}
+
+	value, err := b.new(ctx, t)
+	if err != nil {
+		return zero, err
+	}
+
+	inst, _ := value.Interface().(T)
+
+	return inst, nil
+}
+
+// MustResolve is like Resolve, but panics if the dependency cannot be resolved. It uses the background context,
+// so it is only meant for the composition root during the app startup phase, where the failure to construct
+// a dependency is fatal anyway. Dependencies that take Context should be resolved with Resolve instead.
+func MustResolve[T any](b *Van) T {
+	inst, err := Resolve[T](context.Background(), b)
+	if err != nil {
+		panic(fmt.Errorf("failed to resolve %s: %w", reflect.TypeOf((*T)(nil)).Elem().String(), err))
+	}
+
+	return inst
+}
+
+// typedHandler calls a typed handler, resolving its dependencies from the given bus.
+type typedHandler func(ctx context.Context, b *Van, cmd interface{}) error
+
+// HandleTyped registers a command handler that is called directly rather than via reflection, which
+// makes the dispatch considerably faster for hot commands. HandleTyped1, HandleTyped2 and HandleTyped3
+// are the variants for handlers with one to three dependencies. The dependencies of typed handlers
+// must be interfaces or *van.Van. Unlike the regular handlers, the resolve timeout does not apply to them.
+func HandleTyped[C any](b *Van, handler func(context.Context, *C) error, opts ...HandlerOption) {
+	b.registerTyped(handler, func(ctx context.Context, b *Van, cmd interface{}) error {
+		return handler(ctx, cmd.(*C))
+	}, opts)
+}
+
+// HandleTyped1 registers a typed command handler with one dependency. See HandleTyped.
+func HandleTyped1[C, D1 any](b *Van, handler func(context.Context, *C, D1) error, opts ...HandlerOption) {
+	b.registerTyped(handler, func(ctx context.Context, b *Van, cmd interface{}) error {
+		d1, err := Resolve[D1](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		return handler(ctx, cmd.(*C), d1)
+	}, opts)
+}
+
+// HandleTyped2 registers a typed command handler with two dependencies. See HandleTyped.
+func HandleTyped2[C, D1, D2 any](b *Van, handler func(context.Context, *C, D1, D2) error, opts ...HandlerOption) {
+	b.registerTyped(handler, func(ctx context.Context, b *Van, cmd interface{}) error {
+		d1, err := Resolve[D1](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		d2, err := Resolve[D2](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		return handler(ctx, cmd.(*C), d1, d2)
+	}, opts)
+}
+
+// HandleTyped3 registers a typed command handler with three dependencies. See HandleTyped.
+func HandleTyped3[C, D1, D2, D3 any](b *Van, handler func(context.Context, *C, D1, D2, D3) error, opts ...HandlerOption) {
+	b.registerTyped(handler, func(ctx context.Context, b *Van, cmd interface{}) error {
+		d1, err := Resolve[D1](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		d2, err := Resolve[D2](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		d3, err := Resolve[D3](ctx, b)
+		if err != nil {
+			return err
+		}
+
+		return handler(ctx, cmd.(*C), d1, d2, d3)
+	}, opts)
+}
+
+func (b *Van) registerTyped(handler HandlerFunc, typed typedHandler, opts []HandlerOption) {
+	handlerType := reflect.TypeOf(handler)
+
+	for i := 2; i < handlerType.NumIn(); i++ {
+		if argType := handlerType.In(i); argType.Kind() != reflect.Interface && argType != typeVan {
+			panic(fmt.Errorf("argument %d of a typed handler must be an interface or *van.Van, got %s", i, argType.String()))
+		}
+	}
+
+	opts = append(opts, func(h *handlerOpts) {
+		h.typed = typed
+	})
+
+	cmd := reflect.Zero(handlerType.In(1).Elem()).Interface()
+
+	if err := b.registerHandler(cmd, handler, opts); err != nil {
+		panic(err)
+	}
+}
+
+// ProvideAs registers a provider of type T, which is checked by the compiler rather than at run time.
+// ProvideAs1, ProvideAs2 and ProvideAs3 are the variants for providers with one to three dependencies.
+// It panics if T is not an interface, or if the provider is rejected by Provide.
+func ProvideAs[T any](b *Van, provider func() (T, error), opts ...ProviderOption) {
+	b.registerAs(reflect.TypeOf((*T)(nil)).Elem(), provider, opts)
+}
+
+// ProvideAs1 registers a provider of type T with one dependency. See ProvideAs.
+func ProvideAs1[T, D1 any](b *Van, provider func(D1) (T, error), opts ...ProviderOption) {
+	b.registerAs(reflect.TypeOf((*T)(nil)).Elem(), provider, opts)
+}
+
+// ProvideAs2 registers a provider of type T with two dependencies. See ProvideAs.
+func ProvideAs2[T, D1, D2 any](b *Van, provider func(D1, D2) (T, error), opts ...ProviderOption) {
+	b.registerAs(reflect.TypeOf((*T)(nil)).Elem(), provider, opts)
+}
+
+// ProvideAs3 registers a provider of type T with three dependencies. See ProvideAs.
+func ProvideAs3[T, D1, D2, D3 any](b *Van, provider func(D1, D2, D3) (T, error), opts ...ProviderOption) {
+	b.registerAs(reflect.TypeOf((*T)(nil)).Elem(), provider, opts)
+}
+
+func (b *Van) registerAs(t reflect.Type, provider ProviderFunc, opts []ProviderOption) {
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Errorf("type parameter of ProvideAs must be an interface, got %s", t.String()))
+	}
+
+	b.Provide(provider, opts...)
+}
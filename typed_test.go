@@ -0,0 +1,215 @@
+package van
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHandleTyped(t *testing.T) {
+	var setService SetIntService = &SetIntSevriceImpl{}
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.ProvideOnce(func() (SetIntService, error) {
+		return setService, nil
+	})
+
+	HandleTyped3(bus, func(ctx context.Context, cmd *Command, g GetIntService, s SetIntService, b *Van) error {
+		if s != setService {
+			t.Fatalf("expected %v, got %v", setService, s)
+		}
+
+		if b != bus {
+			t.Fatal("different *Van instance")
+		}
+
+		cmd.Result = g.Get()
+
+		return nil
+	})
+
+	cmd := &Command{}
+
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected result 1, got %d", cmd.Result)
+	}
+}
+
+func TestHandleTyped_ProviderError(t *testing.T) {
+	wantErr := errors.New("provider error")
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return nil, wantErr
+	})
+
+	HandleTyped1(bus, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		t.Fatal("handler should not be called")
+		return nil
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+}
+
+func TestHandleTypedFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "no providers registered for type van.GetIntService", func() {
+		HandleTyped1(bus, func(ctx context.Context, cmd *Command, g GetIntService) error {
+			return nil
+		})
+	})
+
+	panicsWithError(t, "argument 2 of a typed handler must be an interface or *van.Van, got struct { S van.GetIntService }", func() {
+		HandleTyped1(bus, func(ctx context.Context, cmd *Command, deps struct{ S GetIntService }) error {
+			return nil
+		})
+	})
+}
+
+func TestResolve(t *testing.T) {
+	var providerExecuted int
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		providerExecuted++
+		return &GetIntServiceImpl{}, nil
+	})
+
+	ctx := context.Background()
+
+	first, err := Resolve[GetIntService](ctx, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Resolve[GetIntService](ctx, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Fatal("expected the same singleton instance")
+	}
+
+	if providerExecuted != 1 {
+		t.Fatalf("providerExecuted != 1, got %d", providerExecuted)
+	}
+}
+
+func TestResolve_ConcretePointer(t *testing.T) {
+	bus := New()
+
+	type Config struct {
+		Value int
+	}
+
+	bus.ProvideOnce(func() (*Config, error) {
+		return &Config{Value: 42}, nil
+	})
+
+	bus.Provide(func(cfg *Config) (GetIntService, error) {
+		return constIntService(cfg.Value), nil
+	})
+
+	ctx := context.Background()
+
+	svc, err := Resolve[GetIntService](ctx, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Resolve[*Config](ctx, bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svc.Get() != 42 || cfg.Value != 42 {
+		t.Fatalf("expected 42 from both, got %d and %d", svc.Get(), cfg.Value)
+	}
+}
+
+func TestMustResolve(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	if svc := MustResolve[GetIntService](bus); svc.Get() != 1 {
+		t.Fatalf("expected 1, got %d", svc.Get())
+	}
+
+	panicsWithError(t, "failed to resolve van.SetIntService: no providers registered for type van.SetIntService", func() {
+		MustResolve[SetIntService](bus)
+	})
+}
+
+func TestResolveFails(t *testing.T) {
+	wantErr := errors.New("provider error")
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return nil, wantErr
+	})
+
+	ctx := context.Background()
+
+	if _, err := Resolve[GetIntService](ctx, bus); !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+
+	_, err := Resolve[SetIntService](ctx, bus)
+	if err == nil || err.Error() != "no providers registered for type van.SetIntService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = Resolve[int](ctx, bus)
+	if err == nil || err.Error() != "no providers registered for type int" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvideAs(t *testing.T) {
+	bus := New()
+
+	ProvideAs(bus, func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	ProvideAs1(bus, func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	g, err := Resolve[GetIntService](context.Background(), bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Get() != 1 {
+		t.Fatalf("expected 1, got %d", g.Get())
+	}
+}
+
+func TestProvideAsFails(t *testing.T) {
+	panicsWithError(t, "type parameter of ProvideAs must be an interface, got *van.GetIntServiceImpl", func() {
+		ProvideAs(New(), func() (*GetIntServiceImpl, error) {
+			return &GetIntServiceImpl{}, nil
+		})
+	})
+}
@@ -7,27 +7,121 @@ import (
 )
 
 var (
-	typeVan     = reflect.TypeOf((*Van)(nil))
-	typeError   = reflect.TypeOf((*error)(nil)).Elem()
-	typeContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeVan        = reflect.TypeOf((*Van)(nil))
+	typePublisher  = reflect.TypeOf((*Publisher)(nil)).Elem()
+	typeDispatcher = reflect.TypeOf((*Dispatcher)(nil)).Elem()
+	typeScope      = reflect.TypeOf((*Scope)(nil))
+	typeError      = reflect.TypeOf((*error)(nil)).Elem()
+	typeContext    = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeCleanup    = reflect.TypeOf(func() {})
+	typeAny        = reflect.TypeOf((*interface{})(nil)).Elem()
 )
 
 func isStructPtr(t reflect.Type) bool {
 	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
 }
 
+// isProvidedType reports whether values of t can be constructed by providers: interfaces and, for simple
+// leaf dependencies such as configs and clients, concrete struct pointers, slices and maps. Slices of
+// interfaces are not among them, as they are resolved from the group providers.
+func isProvidedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Map:
+		return true
+	case reflect.Slice:
+		return !isGroupType(t)
+	default:
+		return isStructPtr(t)
+	}
+}
+
+// providedTypeOf returns the type from a nil pointer to the type, e.g. (*Logger)(nil) or (*[]string)(nil),
+// which must be a type that providers can construct.
+func providedTypeOf(typePtr interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(typePtr)
+	if t == nil || t.Kind() != reflect.Ptr || !isProvidedType(t.Elem()) {
+		return nil, fmt.Errorf("expected a pointer to an interface, struct pointer, slice or map, got %v", t)
+	}
+
+	return t.Elem(), nil
+}
+
+// SignatureError reports a function of the wrong type passed to the bus, such as a provider or a handler.
+// It matches ErrInvalidSignature with errors.Is.
+type SignatureError struct {
+	// Func is the type of the offending function, which may not even be a function.
+	Func reflect.Type
+	// ArgIndex is the index of the offending argument, or -1 if the problem is not with a single argument.
+	ArgIndex int
+	// Reason describes the problem. It is the message of the error.
+	Reason string
+}
+
+func newSignatureError(t reflect.Type, argIndex int, format string, args ...interface{}) error {
+	return &SignatureError{Func: t, ArgIndex: argIndex, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (e *SignatureError) Error() string {
+	return e.Reason
+}
+
+func (e *SignatureError) Is(target error) bool {
+	return target == ErrInvalidSignature
+}
+
+// eventValue returns the event as a struct value. Events are passed to the listeners by value, but a pointer
+// to the event struct is accepted as well and dereferenced, so that both Event{} and &Event{} can be published.
+func eventValue(event interface{}) (interface{}, error) {
+	v := reflect.ValueOf(event)
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		return event, nil
+	case v.IsValid() && isStructPtr(v.Type()):
+		if v.IsNil() {
+			return nil, fmt.Errorf("event must not be a nil pointer, got %s", v.Type().String())
+		}
+
+		return v.Elem().Interface(), nil
+	default:
+		return nil, fmt.Errorf("event must be a struct value, unlike commands that are passed by pointer, got %v", reflect.TypeOf(event))
+	}
+}
+
+// eventType returns the type of the event struct, dereferencing the pointer to it, if any.
+func eventType(event interface{}) reflect.Type {
+	t := reflect.TypeOf(event)
+	if t != nil && isStructPtr(t) {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// interfaceOf returns the interface type from a nil pointer to the interface, e.g. (*Logger)(nil).
+func interfaceOf(ifacePtr interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(ifacePtr)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		return nil, fmt.Errorf("expected a pointer to an interface, got %v", t)
+	}
+
+	return t.Elem(), nil
+}
+
 func validateProviderSignature(t reflect.Type) error {
 	switch {
 	case t.Kind() != reflect.Func:
-		return fmt.Errorf("provider must be a function, got %s", t.String())
-	case t.NumIn() > maxArgs:
-		return fmt.Errorf("provider must have at most %d arguments, got %d", maxArgs, t.NumIn())
+		return newSignatureError(t, -1, "provider must be a function, got %s", t.String())
 	case t.NumOut() != 2:
-		return fmt.Errorf("provider must have two return values, got %d", t.NumOut())
-	case t.Out(0).Kind() != reflect.Interface:
-		return fmt.Errorf("provider's first return value must be an interface, got %s", t.Out(0).String())
+		return newSignatureError(t, -1, "provider must have two return values, got %d", t.NumOut())
+	case isGroupType(t.Out(0)):
+		return newSignatureError(t, -1, "provider cannot return %s, which is resolved from the group providers", t.Out(0).String())
+	case !isProvidedType(t.Out(0)):
+		return newSignatureError(t, -1, "provider's first return value must be an interface, struct pointer, slice or map, got %s", t.Out(0).String())
+	case t.Out(0) == typeVan || t.Out(0) == typePublisher || t.Out(0) == typeDispatcher || t.Out(0) == typeScope:
+		return newSignatureError(t, -1, "provider cannot return %s, which is provided by the bus", t.Out(0).String())
 	case !t.Out(1).Implements(typeError):
-		return fmt.Errorf("provider's second return value must be an error, got %s", t.Out(1).String())
+		return newSignatureError(t, -1, "provider's second return value must be an error, got %s", t.Out(1).String())
 	}
 
 	if err := validateDependencyArgs(t, 0); err != nil {
@@ -37,48 +131,134 @@ func validateProviderSignature(t reflect.Type) error {
 	return nil
 }
 
-func validateHandlerSignature(t reflect.Type) error {
+func validateCleanupProviderSignature(t reflect.Type) error {
 	switch {
 	case t.Kind() != reflect.Func:
-		return fmt.Errorf("handler must be a function, got %s", t.String())
-	case t.NumIn() < 2:
-		return fmt.Errorf("handler must have at least 2 arguments, got %s", fmt.Sprint(t.NumIn()))
-	case t.NumIn() > maxArgs:
-		return fmt.Errorf("handler must have at most %d arguments, got %d", maxArgs, t.NumIn())
-	case t.In(0) != typeContext:
-		return fmt.Errorf("handler's first argument must be context.Context, got %s", t.In(0).String())
-	case !isStructPtr(t.In(1)):
-		return fmt.Errorf("handler's second argument must be a struct pointer, got %s", t.In(1).String())
-	case t.NumOut() != 1:
-		return fmt.Errorf("handler must have one return value, got %s", fmt.Sprint(t.NumOut()))
-	case !t.Out(0).Implements(typeError):
-		return fmt.Errorf("handler's return type must be error, got %s", t.Out(0).String())
+		return newSignatureError(t, -1, "provider must be a function, got %s", t.String())
+	case t.NumOut() != 3:
+		return newSignatureError(t, -1, "provider must have three return values, got %d", t.NumOut())
+	case isGroupType(t.Out(0)):
+		return newSignatureError(t, -1, "provider cannot return %s, which is resolved from the group providers", t.Out(0).String())
+	case !isProvidedType(t.Out(0)):
+		return newSignatureError(t, -1, "provider's first return value must be an interface, struct pointer, slice or map, got %s", t.Out(0).String())
+	case t.Out(0) == typeVan || t.Out(0) == typePublisher || t.Out(0) == typeDispatcher || t.Out(0) == typeScope:
+		return newSignatureError(t, -1, "provider cannot return %s, which is provided by the bus", t.Out(0).String())
+	case t.Out(1) != typeCleanup:
+		return newSignatureError(t, -1, "provider's second return value must be func(), got %s", t.Out(1).String())
+	case !t.Out(2).Implements(typeError):
+		return newSignatureError(t, -1, "provider's third return value must be an error, got %s", t.Out(2).String())
+	}
+
+	if err := validateDependencyArgs(t, 0); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// messageIndex returns the index of the command or event argument of a handler or listener, which follows
+// the context, unless the context is omitted.
+func messageIndex(t reflect.Type) int {
+	if t.NumIn() > 0 && t.In(0) == typeContext {
+		return 1
+	}
+
+	return 0
+}
+
+func validateHandlerSignature(t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return newSignatureError(t, -1, "handler must be a function, got %s", t.String())
+	}
+
+	i := messageIndex(t)
+
+	switch {
+	case t.NumIn() == 0:
+		return newSignatureError(t, -1, "handler must take the command, got no arguments")
+	case t.NumIn() <= i:
+		return newSignatureError(t, -1, "handler must have at least 2 arguments, got %s", fmt.Sprint(t.NumIn()))
+	case i == 0 && !isStructPtr(t.In(0)):
+		return newSignatureError(t, 0, "handler's first argument must be context.Context or a struct pointer, got %s", t.In(0).String())
+	case !isStructPtr(t.In(i)):
+		return newSignatureError(t, 1, "handler's second argument must be a struct pointer, got %s", t.In(1).String())
+	case t.NumOut() != 1 && t.NumOut() != 2:
+		return newSignatureError(t, -1, "handler must have one or two return values, got %s", fmt.Sprint(t.NumOut()))
+	case t.NumOut() == 1 && !t.Out(0).Implements(typeError):
+		return newSignatureError(t, -1, "handler's return type must be error, got %s", t.Out(0).String())
+	case t.NumOut() == 2 && !t.Out(1).Implements(typeError):
+		return newSignatureError(t, -1, "handler's second return value must be an error, got %s", t.Out(1).String())
+	}
+
+	if err := validateMessagePosition(t, "command"); err != nil {
+		return err
 	}
 
-	if err := validateDependencyArgs(t, 2); err != nil {
+	if err := validateHandlerDependencyArgs(t, i+1); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// validateParameterlessHandlerSignature checks the handler of a command without fields, which may omit
+// the command argument and have its dependencies right after the context, if any.
+func validateParameterlessHandlerSignature(t reflect.Type) error {
+	switch {
+	case t.NumOut() != 1 && t.NumOut() != 2:
+		return newSignatureError(t, -1, "handler must have one or two return values, got %s", fmt.Sprint(t.NumOut()))
+	case t.NumOut() == 1 && !t.Out(0).Implements(typeError):
+		return newSignatureError(t, -1, "handler's return type must be error, got %s", t.Out(0).String())
+	case t.NumOut() == 2 && !t.Out(1).Implements(typeError):
+		return newSignatureError(t, -1, "handler's second return value must be an error, got %s", t.Out(1).String())
+	}
+
+	return validateHandlerDependencyArgs(t, messageIndex(t))
+}
+
+// takesCommand reports whether the handler takes the command after the optional context, which only
+// the handlers of commands without fields may omit.
+func takesCommand(handlerType, cmdType reflect.Type) bool {
+	i := messageIndex(handlerType)
+	return handlerType.NumIn() > i && handlerType.In(i) == reflect.PointerTo(cmdType)
+}
+
+// handlerDepsStart returns the index of the first dependency of the handler of the command type.
+func handlerDepsStart(handlerType, cmdType reflect.Type) int {
+	if takesCommand(handlerType, cmdType) {
+		return messageIndex(handlerType) + 1
+	}
+
+	return messageIndex(handlerType)
+}
+
 func validateListenerSignature(t reflect.Type) error {
+	if t.Kind() != reflect.Func {
+		return newSignatureError(t, -1, "handler must be a function, got %s", t.String())
+	}
+
+	i := messageIndex(t)
+
 	switch {
-	case t.Kind() != reflect.Func:
-		return fmt.Errorf("handler must be a function, got %s", t.String())
-	case t.NumIn() < 2:
-		return fmt.Errorf("handler must have at least 2 arguments, got %s", fmt.Sprint(t.NumIn()))
-	case t.NumIn() > maxArgs:
-		return fmt.Errorf("handler must have at most %d arguments, got %d", maxArgs, t.NumIn())
-	case t.In(0) != typeContext:
-		return fmt.Errorf("handler's first argument must be context.Context, got %s", t.In(0).String())
-	case t.In(1).Kind() != reflect.Struct:
-		return fmt.Errorf("handler's second argument must be a struct, got %s", t.In(1).String())
-	case t.NumOut() != 0:
-		return fmt.Errorf("event handler should not have any return values")
-	}
-
-	if err := validateDependencyArgs(t, 2); err != nil {
+	case t.NumIn() == 0:
+		return newSignatureError(t, -1, "handler must take the event, got no arguments")
+	case t.NumIn() <= i:
+		return newSignatureError(t, -1, "handler must have at least 2 arguments, got %s", fmt.Sprint(t.NumIn()))
+	case i == 0 && t.In(0).Kind() != reflect.Struct && t.In(0) != typeAny:
+		return newSignatureError(t, 0, "handler's first argument must be context.Context or a struct, got %s", t.In(0).String())
+	case t.In(i).Kind() != reflect.Struct && t.In(i) != typeAny:
+		return newSignatureError(t, 1, "handler's second argument must be a struct, got %s", t.In(1).String())
+	case t.NumOut() > 1:
+		return newSignatureError(t, -1, "event handler must have at most one return value, got %d", t.NumOut())
+	case t.NumOut() == 1 && !t.Out(0).Implements(typeError):
+		return newSignatureError(t, -1, "event handler's return type must be error, got %s", t.Out(0).String())
+	}
+
+	if err := validateMessagePosition(t, "event"); err != nil {
+		return err
+	}
+
+	if err := validateDependencyArgs(t, i+1); err != nil {
 		return err
 	}
 
@@ -88,13 +268,11 @@ func validateListenerSignature(t reflect.Type) error {
 func validateExecLambdaSignature(t reflect.Type) error {
 	switch {
 	case t.Kind() != reflect.Func:
-		return fmt.Errorf("function must be a function, got %s", t.String())
-	case t.NumIn() > maxArgs:
-		return fmt.Errorf("function must have at most %d arguments, got %d", maxArgs, t.NumIn())
+		return newSignatureError(t, -1, "function must be a function, got %s", t.String())
 	case t.NumOut() != 1:
-		return fmt.Errorf("function must have one return value, got %s", fmt.Sprint(t.NumOut()))
+		return newSignatureError(t, -1, "function must have one return value, got %s", fmt.Sprint(t.NumOut()))
 	case !t.Out(0).Implements(typeError):
-		return fmt.Errorf("return value must be an error, got %s", t.Out(0).String())
+		return newSignatureError(t, -1, "return value must be an error, got %s", t.Out(0).String())
 	}
 
 	if err := validateDependencyArgs(t, 0); err != nil {
@@ -104,39 +282,122 @@ func validateExecLambdaSignature(t reflect.Type) error {
 	return nil
 }
 
+func validateExecValueSignature(t, valueType reflect.Type) error {
+	switch {
+	case t.Kind() != reflect.Func:
+		return newSignatureError(t, -1, "function must be a function, got %s", t.String())
+	case t.NumOut() != 2:
+		return newSignatureError(t, -1, "function must have two return values, got %s", fmt.Sprint(t.NumOut()))
+	case !t.Out(0).AssignableTo(valueType):
+		return newSignatureError(t, -1, "first return value must be assignable to %s, got %s", valueType.String(), t.Out(0).String())
+	case !t.Out(1).Implements(typeError):
+		return newSignatureError(t, -1, "second return value must be an error, got %s", t.Out(1).String())
+	}
+
+	return validateDependencyArgs(t, 0)
+}
+
+// validateMessagePosition checks that the command or event, which is always passed right after the optional
+// context of a handler or listener, does not appear among the dependencies.
+func validateMessagePosition(t reflect.Type, kind string) error {
+	msg := messageIndex(t)
+
+	position := "second"
+	if msg == 0 {
+		position = "first"
+	}
+
+	for i := msg + 1; i < t.NumIn(); i++ {
+		if t.In(i) == t.In(msg) {
+			return newSignatureError(t, i, "argument %d is the %s, which is only allowed as the %s argument", i, kind, position)
+		}
+	}
+
+	return nil
+}
+
+// validateDependencyArgs checks the dependencies of a function, starting from the given argument.
 func validateDependencyArgs(t reflect.Type, start int) error {
+	return validateArgs(t, start, false)
+}
+
+// validateHandlerDependencyArgs checks the dependencies of a command handler, which, unlike the other
+// functions, may also take *van.Scope.
+func validateHandlerDependencyArgs(t reflect.Type, start int) error {
+	return validateArgs(t, start, true)
+}
+
+func validateArgs(t reflect.Type, start int, allowScope bool) error {
 	for i := start; i < t.NumIn(); i++ {
 		argType := t.In(i)
 
+		if argType == typeScope && !allowScope {
+			return newSignatureError(t, i, "argument %d is *van.Scope, which is only available in command handlers", i)
+		}
+
 		switch argType.Kind() {
 		case reflect.Interface:
+			if argType == typeContext && i != 0 {
+				return newSignatureError(t, i, "argument %d is context.Context, which is only allowed as the first argument", i)
+			}
+
+			continue
+		case reflect.Slice, reflect.Map:
 			continue
 		case reflect.Ptr:
-			if argType != typeVan {
-				return fmt.Errorf("argument %d must be an interface, struct or *van.Van, got %s", i, argType.String())
+			if !isStructPtr(argType) {
+				return newSignatureError(t, i, "argument %d must be an interface, struct, struct pointer, slice or map, got %s", i, argType.String())
 			}
 		case reflect.Struct:
+			if depType, ok := optionalType(argType); ok {
+				if depType.Kind() != reflect.Interface {
+					return newSignatureError(t, i, "argument %d must be an optional interface, got %s", i, argType.String())
+				}
+
+				continue
+			}
+
 			if err := validateDependencyStruct(argType); err != nil {
-				return fmt.Errorf("error in dependency struct argument %d: %w", i, err)
+				return newSignatureError(t, i, "error in dependency struct argument %d: %v", i, err)
 			}
 
 			continue
 		default:
-			return fmt.Errorf("argument %d must be an interface, struct or *van.Van, got %s", i, argType.String())
+			return newSignatureError(t, i, "argument %d must be an interface, struct, struct pointer, slice or map, got %s", i, argType.String())
 		}
 	}
 
 	return nil
 }
 
+// validateDependencyStruct checks the fields of a dependency struct, which are either of the types provided
+// by the providers or nested dependency structs, embedded or not.
 func validateDependencyStruct(t reflect.Type) error {
-	for _, f := range reflect.VisibleFields(t) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
 		if !f.IsExported() {
 			return fmt.Errorf("field %s must be exported", f.Name)
 		}
 
-		if f.Type.Kind() != reflect.Interface {
-			return fmt.Errorf("field %s must be an interface, got %s", f.Name, f.Type.String())
+		if _, ok := optionalType(f.Type); !ok && f.Type.Kind() == reflect.Struct {
+			if err := validateDependencyStruct(f.Type); err != nil {
+				return fmt.Errorf("in field %s: %w", f.Name, err)
+			}
+
+			continue
+		}
+
+		if !isProvidedType(f.Type) {
+			return fmt.Errorf("field %s must be an interface, struct pointer, slice or map, got %s", f.Name, f.Type.String())
+		}
+
+		if f.Type == typeContext {
+			return fmt.Errorf("field %s cannot be context.Context, which is only allowed as the first argument", f.Name)
+		}
+
+		if f.Type == typeScope {
+			return fmt.Errorf("field %s cannot be *van.Scope, which is only allowed as a command handler argument", f.Name)
 		}
 	}
 
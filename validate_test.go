@@ -2,6 +2,7 @@ package van
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -30,6 +31,14 @@ func TestValidateProviderSignature(t *testing.T) {
 			provider: func(context.Context) (interface{}, error) { return nil, nil },
 			wantOk:   true,
 		},
+		"struct pointer provider": {
+			provider: func(context.Context, *struct{}) (*struct{ A int }, error) { return nil, nil },
+			wantOk:   true,
+		},
+		"returns the bus": {
+			provider: func(context.Context) (*Van, error) { return nil, nil },
+			wantErr:  "provider cannot return *van.Van, which is provided by the bus",
+		},
 		"not a function": {
 			provider: 0,
 			wantErr:  "provider must be a function, got int",
@@ -44,7 +53,7 @@ func TestValidateProviderSignature(t *testing.T) {
 		},
 		"first return value not interface": {
 			provider: func(context.Context) (int, error) { return 0, nil },
-			wantErr:  "provider's first return value must be an interface, got int",
+			wantErr:  "provider's first return value must be an interface, struct pointer, slice or map, got int",
 		},
 		"second return value not error": {
 			provider: func(context.Context) (interface{}, int) { return nil, 0 },
@@ -52,7 +61,7 @@ func TestValidateProviderSignature(t *testing.T) {
 		},
 		"argument not interface": {
 			provider: func(context.Context, int) (interface{}, error) { return nil, nil },
-			wantErr:  "argument 1 must be an interface, struct or *van.Van, got int",
+			wantErr:  "argument 1 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"dependency struct field is not exported": {
 			provider: func(context.Context, struct{ s interface{} }) (interface{}, error) { return nil, nil },
@@ -60,7 +69,11 @@ func TestValidateProviderSignature(t *testing.T) {
 		},
 		"dependency struct field is not an interface": {
 			provider: func(context.Context, struct{ S int }) (interface{}, error) { return nil, nil },
-			wantErr:  "error in dependency struct argument 1: field S must be an interface, got int",
+			wantErr:  "error in dependency struct argument 1: field S must be an interface, struct pointer, slice or map, got int",
+		},
+		"context is not the first argument": {
+			provider: func(interface{}, context.Context) (interface{}, error) { return nil, nil },
+			wantErr:  "argument 1 is context.Context, which is only allowed as the first argument",
 		},
 	}
 
@@ -96,6 +109,14 @@ func TestValidateHandlerSignature(t *testing.T) {
 			handler: func(context.Context, *struct{}, interface{}) error { return nil },
 			wantOk:  true,
 		},
+		"nested dependency struct": {
+			handler: func(context.Context, *struct{}, struct{ N struct{ S interface{} } }) error { return nil },
+			wantOk:  true,
+		},
+		"nested dependency struct field is not an interface": {
+			handler: func(context.Context, *struct{}, struct{ N struct{ S int } }) error { return nil },
+			wantErr: "error in dependency struct argument 2: in field N: field S must be an interface, struct pointer, slice or map, got int",
+		},
 		"not a function": {
 			handler: 0,
 			wantErr: "handler must be a function, got int",
@@ -106,7 +127,7 @@ func TestValidateHandlerSignature(t *testing.T) {
 		},
 		"first argument is not a not context": {
 			handler: func(int, *struct{}, interface{}) error { return nil },
-			wantErr: "handler's first argument must be context.Context, got int",
+			wantErr: "handler's first argument must be context.Context or a struct pointer, got int",
 		},
 		"second argument is not a pointer to struct": {
 			handler: func(context.Context, int, interface{}) error { return nil },
@@ -114,7 +135,7 @@ func TestValidateHandlerSignature(t *testing.T) {
 		},
 		"third argument is not an interface": {
 			handler: func(context.Context, *struct{}, int) error { return nil },
-			wantErr: "argument 2 must be an interface, struct or *van.Van, got int",
+			wantErr: "argument 2 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"dependency struct field is not exported": {
 			handler: func(context.Context, *struct{}, struct{ s interface{} }) error { return nil },
@@ -122,15 +143,31 @@ func TestValidateHandlerSignature(t *testing.T) {
 		},
 		"dependency struct field is not an interface": {
 			handler: func(context.Context, *struct{}, struct{ S int }) error { return nil },
-			wantErr: "error in dependency struct argument 2: field S must be an interface, got int",
+			wantErr: "error in dependency struct argument 2: field S must be an interface, struct pointer, slice or map, got int",
+		},
+		"context in the middle": {
+			handler: func(context.Context, *struct{}, interface{}, context.Context) error { return nil },
+			wantErr: "argument 3 is context.Context, which is only allowed as the first argument",
+		},
+		"dependency struct field is context": {
+			handler: func(context.Context, *struct{}, struct{ Ctx context.Context }) error { return nil },
+			wantErr: "error in dependency struct argument 2: field Ctx cannot be context.Context, which is only allowed as the first argument",
+		},
+		"command in the middle": {
+			handler: func(context.Context, *struct{}, *struct{}) error { return nil },
+			wantErr: "argument 2 is the command, which is only allowed as the second argument",
 		},
 		"no return values": {
 			handler: func(context.Context, *struct{}, interface{}) {},
-			wantErr: "handler must have one return value, got 0",
+			wantErr: "handler must have one or two return values, got 0",
 		},
 		"too many return values": {
-			handler: func(context.Context, *struct{}, interface{}) (interface{}, error) { return nil, nil },
-			wantErr: "handler must have one return value, got 2",
+			handler: func(context.Context, *struct{}, interface{}) (int, int, error) { return 0, 0, nil },
+			wantErr: "handler must have one or two return values, got 3",
+		},
+		"second return value is not an error": {
+			handler: func(context.Context, *struct{}, interface{}) (int, int) { return 0, 0 },
+			wantErr: "handler's second return value must be an error, got int",
 		},
 		"return value is not an error": {
 			handler: func(context.Context, *struct{}, interface{}) int { return 0 },
@@ -180,7 +217,7 @@ func TestValidateListenerSignature(t *testing.T) {
 		},
 		"first argument is not a not context": {
 			listener: func(int, struct{}, interface{}) {},
-			wantErr:  "handler's first argument must be context.Context, got int",
+			wantErr:  "handler's first argument must be context.Context or a struct, got int",
 		},
 		"second argument is not a struct": {
 			listener: func(context.Context, int, interface{}) {},
@@ -188,7 +225,7 @@ func TestValidateListenerSignature(t *testing.T) {
 		},
 		"third argument is not an interface": {
 			listener: func(context.Context, struct{}, int) {},
-			wantErr:  "argument 2 must be an interface, struct or *van.Van, got int",
+			wantErr:  "argument 2 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"dependency struct field is not exported": {
 			listener: func(context.Context, struct{}, struct{ s interface{} }) {},
@@ -196,11 +233,23 @@ func TestValidateListenerSignature(t *testing.T) {
 		},
 		"dependency struct field is not an interface": {
 			listener: func(context.Context, struct{}, struct{ S int }) {},
-			wantErr:  "error in dependency struct argument 2: field S must be an interface, got int",
+			wantErr:  "error in dependency struct argument 2: field S must be an interface, struct pointer, slice or map, got int",
 		},
-		"too many return values": {
+		"event in the middle": {
+			listener: func(context.Context, struct{ V int }, struct{ V int }) {},
+			wantErr:  "argument 2 is the event, which is only allowed as the second argument",
+		},
+		"returns an error": {
+			listener: func(context.Context, struct{}, interface{}) error { return nil },
+			wantOk:   true,
+		},
+		"return value is not an error": {
 			listener: func(context.Context, struct{}, interface{}) int { return 0 },
-			wantErr:  "event handler should not have any return values",
+			wantErr:  "event handler's return type must be error, got int",
+		},
+		"too many return values": {
+			listener: func(context.Context, struct{}, interface{}) (int, error) { return 0, nil },
+			wantErr:  "event handler must have at most one return value, got 2",
 		},
 	}
 
@@ -254,7 +303,7 @@ func TestValidateExecLambdaSignature(t *testing.T) {
 		},
 		"dependency is not an interface": {
 			fn:      func(int) error { return nil },
-			wantErr: "argument 0 must be an interface, struct or *van.Van, got int",
+			wantErr: "argument 0 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"dependency struct field is not exported": {
 			fn:      func(struct{ s interface{} }) error { return nil },
@@ -262,7 +311,7 @@ func TestValidateExecLambdaSignature(t *testing.T) {
 		},
 		"dependency struct field is not an interface": {
 			fn:      func(struct{ S int }) error { return nil },
-			wantErr: "error in dependency struct argument 0: field S must be an interface, got int",
+			wantErr: "error in dependency struct argument 0: field S must be an interface, struct pointer, slice or map, got int",
 		},
 	}
 
@@ -287,3 +336,106 @@ func TestValidateExecLambdaSignature(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateExecValueSignature(t *testing.T) {
+	tests := map[string]struct {
+		fn      interface{}
+		wantErr string
+		wantOk  bool
+	}{
+		"valid": {
+			fn:     func(dep interface{}) (int, error) { return 0, nil },
+			wantOk: true,
+		},
+		"one return value": {
+			fn:      func() error { return nil },
+			wantErr: "function must have two return values, got 1",
+		},
+		"value is not assignable": {
+			fn:      func() (string, error) { return "", nil },
+			wantErr: "first return value must be assignable to int, got string",
+		},
+		"second return value is not an error": {
+			fn:      func() (int, int) { return 0, 0 },
+			wantErr: "second return value must be an error, got int",
+		},
+		"dependency is not an interface": {
+			fn:      func(int) (int, error) { return 0, nil },
+			wantErr: "argument 0 must be an interface, struct, struct pointer, slice or map, got int",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateExecValueSignature(reflect.TypeOf(tt.fn), typeOf[int]())
+
+			if tt.wantOk {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+
+				if err.Error() != tt.wantErr {
+					t.Errorf("expected error %q, got %q", tt.wantErr, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureError(t *testing.T) {
+	tests := map[string]struct {
+		fn           interface{}
+		validate     func(reflect.Type) error
+		wantArgIndex int
+		wantErr      string
+	}{
+		"dependency argument": {
+			fn:           func(context.Context, *Command, int) error { return nil },
+			validate:     validateHandlerSignature,
+			wantArgIndex: 2,
+			wantErr:      "argument 2 must be an interface, struct, struct pointer, slice or map, got int",
+		},
+		"dependency struct argument": {
+			fn:           func(context.Context, struct{ S int }) (interface{}, error) { return nil, nil },
+			validate:     validateProviderSignature,
+			wantArgIndex: 1,
+			wantErr:      "error in dependency struct argument 1: field S must be an interface, struct pointer, slice or map, got int",
+		},
+		"first argument": {
+			fn:           func(int, Event) {},
+			validate:     validateListenerSignature,
+			wantArgIndex: 0,
+			wantErr:      "handler's first argument must be context.Context or a struct, got int",
+		},
+		"return value": {
+			fn:           func(context.Context) (int, error) { return 0, nil },
+			validate:     validateProviderSignature,
+			wantArgIndex: -1,
+			wantErr:      "provider's first return value must be an interface, struct pointer, slice or map, got int",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fnType := reflect.TypeOf(tt.fn)
+			err := tt.validate(fnType)
+
+			var sigErr *SignatureError
+			if !errors.As(err, &sigErr) {
+				t.Fatalf("expected a *SignatureError, got %T", err)
+			}
+
+			if sigErr.Func != fnType || sigErr.ArgIndex != tt.wantArgIndex || sigErr.Reason != tt.wantErr {
+				t.Errorf("got %+v", sigErr)
+			}
+
+			if err.Error() != tt.wantErr || !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
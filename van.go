@@ -2,385 +2,1860 @@ package van
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// maxArgs is the maximum number of arguments (dependencies) a function can have.
-// Since we don't want to allocate a dynamic slice for every function call, we use
-// a fixed size array. One can always bypass this limitation by using a dependency struct.
+// maxArgs is the number of arguments (dependencies) a function can have without extra allocations.
+// Since we don't want to allocate a dynamic slice for every function call, we use a fixed size array,
+// and only fall back to a heap-allocated slice for functions with more arguments.
 const maxArgs = 16
 
+// argsBuffer returns a slice for n arguments backed by the stack array, if the arguments fit into it.
+func argsBuffer(stack []reflect.Value, n int) []reflect.Value {
+	if n <= len(stack) {
+		return stack[:n]
+	}
+
+	return make([]reflect.Value, n)
+}
+
 type ProviderFunc interface{} // func(ctx context.Context, deps ...interface{}) (interface{}, error)
 type HandlerFunc interface{}  // func(ctx context.Context, cmd interface{}, deps ...interface{}) error
-type ListenerFunc interface{} // func(ctx context.Context, event interface{}, deps ...interface)
+type ListenerFunc interface{} // func(ctx context.Context, event interface{}, deps ...interface) [error]
+
+// providerKey identifies a provider by the type it constructs and an optional name,
+// which allows to register several implementations of the same interface.
+type providerKey struct {
+	typ  reflect.Type
+	name string
+}
+
+func (k providerKey) String() string {
+	if k.name == "" {
+		return k.typ.String()
+	}
+
+	return fmt.Sprintf("%s named %q", k.typ.String(), k.name)
+}
 
 type providerOpts struct {
-	sync.RWMutex
+	sync.RWMutex // guards the instance
 
 	fn           ProviderFunc
+	name         string
 	instance     interface{}
 	singleton    bool
 	takesContext bool
+
+	// building is a semaphore held while the singleton is being constructed.
+	building chan struct{}
+
+	// autoClose is set for providers whose instances are closed once the call is complete,
+	// usesAutoClose is set if the provider or any of its dependencies is auto-closed.
+	autoClose     bool
+	usesAutoClose bool
+
+	// cleanup is set for providers registered with ProvideWithCleanup,
+	// which return a cleanup function along with the instance.
+	cleanup bool
+
+	// group is set for providers registered with ProvideGroup.
+	group bool
+
+	// scoped is set for providers registered with ProvideScoped.
+	scoped bool
+
+	// lazy is set for providers registered with ProvideLazy, whose fn is only a placeholder.
+	lazy *lazyProvider
 }
 
-func (p *providerOpts) call(args []reflect.Value) (reflect.Value, error) {
-	ret := reflect.ValueOf(p.fn).Call(args)
+// call calls the provider function, which is either fn or the one produced by the lazy provider.
+func (p *providerOpts) call(fn ProviderFunc, args []reflect.Value) (reflect.Value, func(), error) {
+	ret := reflect.ValueOf(fn).Call(args)
+
+	if p.cleanup {
+		cleanup, _ := ret[1].Interface().(func())
+		return ret[0], cleanup, toError(ret[2])
+	}
+
 	instance, err := ret[0], toError(ret[1])
 
-	return instance, err
+	return instance, nil, err
+}
+
+type handlerOpts struct {
+	fn          HandlerFunc
+	idempotency *idempotency
+	middleware  []Middleware
+	chain       InvokeFunc
+	typed       typedHandler
+	timeout     time.Duration
+	retry       *RetryPolicy
+	pipeline    []HandlerFunc
+}
+
+// funcs returns the handler functions, which is more than one for a pipeline.
+func (h *handlerOpts) funcs() []HandlerFunc {
+	if h.pipeline != nil {
+		return h.pipeline
+	}
+
+	return []HandlerFunc{h.fn}
 }
 
+// Van is a command and event bus with dependency injection. It is safe for concurrent use, including
+// registering providers, handlers and listeners while commands are being invoked and events published.
 type Van struct {
-	providers map[reflect.Type]*providerOpts
-	listeners map[reflect.Type][]HandlerFunc
-	handlers  map[reflect.Type]HandlerFunc
+	providers map[providerKey]*providerOpts
+	groups    map[reflect.Type][]*providerOpts
+	listeners map[reflect.Type][]*listenerOpts
+	handlers  map[reflect.Type]*handlerOpts
 	wg        sync.WaitGroup
+	now       func() time.Time
+
+	// mu guards the providers, groups, handlers, listeners and decorators. It is only held for the lookups,
+	// but never while the dependencies are constructed or the handlers are called. The listener slices are
+	// never modified in place, so that they can be iterated without holding the lock.
+	mu sync.RWMutex
+
+	resolveTimeout       time.Duration
+	slowResolveThreshold time.Duration
+	hasAutoClose         atomic.Bool
+	hasScoped            atomic.Bool
+	deferValidation      bool
+	recoverPanics        bool
+	strictBus            bool
+
+	listenerSlots   chan struct{}
+	activeListeners int32
+	deadLetter      DeadLetterFunc
+
+	resolveSlots chan struct{}
+	waits        waitGraph
+	panicHandler PanicHandler
+
+	unhandledCommand UnhandledCommandFunc
+
+	// concrete holds the singleton instances by their concrete types, which is guarded by mu.
+	concrete map[reflect.Type]interface{}
+
+	dedupKey  func(event interface{}) string
+	dedupSeen *ttlSet
+
+	interceptor DependencyInterceptor
+	decorators  map[reflect.Type][]Decorator
+	middleware  []Middleware
+	hooks       Hooks
+	tracer      Tracer
+	logger      Logger
+
+	cleanupMu sync.Mutex
+	cleanups  []func()
+
+	recorder recorder
 }
 
-func New() *Van {
-	return &Van{
-		providers: make(map[reflect.Type]*providerOpts),
-		listeners: make(map[reflect.Type][]HandlerFunc),
-		handlers:  make(map[reflect.Type]HandlerFunc),
+func New(opts ...Option) *Van {
+	b := &Van{
+		providers:  make(map[providerKey]*providerOpts),
+		groups:     make(map[reflect.Type][]*providerOpts),
+		listeners:  make(map[reflect.Type][]*listenerOpts),
+		handlers:   make(map[reflect.Type]*handlerOpts),
+		decorators: make(map[reflect.Type][]Decorator),
+		concrete:   make(map[reflect.Type]interface{}),
+		now:        time.Now,
+		logger:     log.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(b)
 	}
+
+	return b
 }
 
-// Wait blocks until all current events are processed, which may be used for implementing graceful shutdown.
+// Wait blocks until all current events and asynchronous commands are processed, which may be used for
+// implementing graceful shutdown.
 // It is up to the programmer to ensure that no new events/commands are published, otherwise it may run forever.
 func (b *Van) Wait() {
 	b.wg.Wait()
 }
 
+// WaitContext is like Wait, but gives up once the context is done and returns ctx.Err(), so that the graceful
+// shutdown is bounded by a deadline. The events and commands still being processed keep running in the background.
+func (b *Van) WaitContext(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown calls the cleanup functions of the singletons registered with ProvideWithCleanup in reverse order
+// of their construction, so that the instances are cleaned up before their dependencies. Panics in the cleanup
+// functions are recovered, and reported in the returned error along with the cancellation of the context,
+// after which the remaining cleanups are skipped. The bus should not be used once it is shut down.
+func (b *Van) Shutdown(ctx context.Context) error {
+	b.cleanupMu.Lock()
+	cleanups := b.cleanups
+	b.cleanups = nil
+	b.cleanupMu.Unlock()
+
+	var errs []error
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown interrupted: %w", err))
+			break
+		}
+
+		if err := runCleanup(cleanups[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func runCleanup(cleanup func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cleanup panicked: %v", r)
+		}
+	}()
+
+	cleanup()
+
+	return nil
+}
+
+// ActiveListeners returns the number of event listeners that are being executed at the moment.
+func (b *Van) ActiveListeners() int {
+	return int(atomic.LoadInt32(&b.activeListeners))
+}
+
 // Provide registers new type constructor that will be called every time a handler requests the dependency.
-// There's no such thing as "optional" dependency. Therefore, the provider should either return a valid non-nil
-// dependency or an error.
+// The constructed type is either an interface or, for simple leaf dependencies, a concrete struct pointer,
+// e.g. *Config, which is then requested by the same pointer type. There's no such thing as "optional"
+// dependency. Therefore, the provider should either return a valid non-nil dependency or an error.
 // It is expected to be called during the app startup phase as it performs the run time type checking and
 // panics if an incorrect function type is provided.
-func (b *Van) Provide(provider ProviderFunc) {
-	if err := b.registerProvider(provider, false); err != nil {
+func (b *Van) Provide(provider ProviderFunc, opts ...ProviderOption) {
+	if err := b.registerProvider(provider, false, opts); err != nil {
 		panic(err)
 	}
 }
 
+// TryProvide registers a type constructor, same as Provide, but returns the error instead of panicking.
+// It is meant for the providers registered at run time, e.g. by plugins.
+func (b *Van) TryProvide(provider ProviderFunc, opts ...ProviderOption) error {
+	return b.registerProvider(provider, false, opts)
+}
+
+// CheckProvider runs the same checks as Provide, including the presence of the providers for the dependencies,
+// but does not register the provider. It is meant for linters and code generators doing a dry run of the wiring.
+func (b *Van) CheckProvider(provider ProviderFunc, opts ...ProviderOption) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, err := b.checkProvider(provider, false, opts)
+
+	return err
+}
+
 // ProvideOnce registers a new type constructor that is guaranteed to be called not more than once in
 // application's lifetime.
+// The instance can also be injected by its concrete type if it is a struct pointer, e.g. *InMemoryCounter for
+// the instance of Counter, unless the type has a provider of its own. Such dependencies are accepted as long as
+// the struct pointer implements the interface of a singleton, which is constructed on first use if needed.
 // It is expected to be called during the app startup phase as it performs the run time type checking and
 // panics if an incorrect function type is provided.
-func (b *Van) ProvideOnce(provider ProviderFunc) {
-	if err := b.registerProvider(provider, true); err != nil {
+func (b *Van) ProvideOnce(provider ProviderFunc, opts ...ProviderOption) {
+	if err := b.registerProvider(provider, true, opts); err != nil {
+		panic(err)
+	}
+}
+
+// ProvideAlso registers a type constructor, same as Provide, and makes the instances it returns available as
+// each of the given interfaces, which are passed as nil pointers to the interfaces, e.g. (*io.Reader)(nil).
+// The return type of the provider must implement all of them. The secondary interfaces are resolved through
+// the primary one, so the provider is called once per resolved dependency, whichever interface is requested.
+// It panics if an incorrect function type is provided or the return type does not implement an interface.
+func (b *Van) ProvideAlso(provider ProviderFunc, ifacePtrs ...interface{}) {
+	if err := b.registerAlso(provider, ifacePtrs); err != nil {
 		panic(err)
 	}
 }
 
-func (b *Van) registerProvider(provider ProviderFunc, signleton bool) error {
+func (b *Van) registerAlso(provider ProviderFunc, ifacePtrs []interface{}) error {
 	providerType := reflect.TypeOf(provider)
 	if err := validateProviderSignature(providerType); err != nil {
 		return err
 	}
 
 	retType := providerType.Out(0)
-	takesContext := false
+	aliases := make([]reflect.Type, len(ifacePtrs))
 
-	for i := 0; i < providerType.NumIn(); i++ {
-		inType := providerType.In(i)
-
-		if inType == retType {
-			return fmt.Errorf("provider function has a dependency of the same type")
-		}
-
-		if err := b.validateDependency(inType); err != nil {
+	for i, ifacePtr := range ifacePtrs {
+		ifaceType, err := interfaceOf(ifacePtr)
+		if err != nil {
 			return err
 		}
 
-		if inType == typeContext {
-			if signleton {
-				return fmt.Errorf("singleton providers cannot use Context as a dependency")
-			}
-
-			takesContext = true
+		if !retType.Implements(ifaceType) {
+			return fmt.Errorf("%s does not implement %s", retType.String(), ifaceType.String())
 		}
 
-		if pp, ok := b.providers[inType]; ok && pp.takesContext {
-			if signleton {
-				return fmt.Errorf("singleton providers cannot depend on providers that take Context")
-			}
+		aliases[i] = ifaceType
+	}
 
-			takesContext = true
-		}
+	if err := b.registerProvider(provider, false, nil); err != nil {
+		return err
 	}
 
-	b.providers[retType] = &providerOpts{
-		fn:           provider,
-		singleton:    signleton,
-		takesContext: takesContext,
+	for _, ifaceType := range aliases {
+		fnType := reflect.FuncOf([]reflect.Type{retType}, []reflect.Type{ifaceType, typeError}, false)
+		fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{args[0], reflect.Zero(typeError)}
+		})
+
+		if err := b.registerProvider(fn.Interface(), false, nil); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Handle registers a handler for the given command type. There can be only one handler per command.
-// It is expected to be called during the app startup phase as it performs the run time type checking and
-// panics if an incorrect function type is provided.
-func (b *Van) Handle(cmd interface{}, handler HandlerFunc) {
-	if err := b.registerHandler(cmd, handler); err != nil {
+// ProvideValueAs registers an already constructed instance as a singleton of the interface type, which is
+// passed as a nil pointer to the interface, e.g. (*Config)(nil). Configuration-like values can also be provided
+// as a slice or a map, e.g. ProvideValueAs((*[]string)(nil), hosts), and are then requested by the exact type.
+// It panics if the instance does not implement the interface, or is not of the given type.
+func (b *Van) ProvideValueAs(targetPtr interface{}, instance interface{}, opts ...ProviderOption) {
+	if err := b.registerValue(targetPtr, instance, opts); err != nil {
 		panic(err)
 	}
 }
 
-func (b *Van) registerHandler(cmd interface{}, handler HandlerFunc) error {
-	cmdType := reflect.TypeOf(cmd)
-	if cmdType.Kind() != reflect.Struct {
-		return fmt.Errorf("cmd must be a struct, got %s", cmdType.Name())
+func (b *Van) registerValue(targetPtr interface{}, instance interface{}, opts []ProviderOption) error {
+	ifaceType, err := providedTypeOf(targetPtr)
+	if err != nil {
+		return err
 	}
 
-	handlerType := reflect.TypeOf(handler)
-	if err := validateHandlerSignature(handlerType); err != nil {
+	switch {
+	case ifaceType.Kind() == reflect.Interface:
+		if instance == nil || !reflect.TypeOf(instance).Implements(ifaceType) {
+			return fmt.Errorf("%T does not implement %s", instance, ifaceType.String())
+		}
+	case reflect.TypeOf(instance) != ifaceType:
+		return fmt.Errorf("%T is not of type %s", instance, ifaceType.String())
+	}
+
+	// the provider is never called since the instance is already there,
+	// but it keeps the introspection of the providers uniform
+	fnType := reflect.FuncOf(nil, []reflect.Type{ifaceType, typeError}, false)
+	fn := reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.ValueOf(instance), reflect.Zero(typeError)}
+	})
+
+	opts = append(opts, func(p *providerOpts) {
+		p.instance = instance
+	})
+
+	return b.registerProvider(fn.Interface(), true, opts)
+}
+
+// Bind registers a provider of the interface that constructs the concrete struct by populating its fields from
+// the bus, same as a dependency struct, so that the implementations that only need their dependencies do not
+// need a constructor. Both types are passed as nil pointers, e.g. Bind((*Logger)(nil), (*StdLogger)(nil)).
+// All fields of the struct must be exported and injectable. It panics if the struct pointer does not implement
+// the interface, or the struct has fields that cannot be injected.
+func (b *Van) Bind(ifacePtr interface{}, concretePtr interface{}, opts ...ProviderOption) {
+	if err := b.registerBind(ifacePtr, concretePtr, opts); err != nil {
+		panic(err)
+	}
+}
+
+func (b *Van) registerBind(ifacePtr interface{}, concretePtr interface{}, opts []ProviderOption) error {
+	ifaceType, err := interfaceOf(ifacePtr)
+	if err != nil {
 		return err
 	}
 
-	if cmdType != handlerType.In(1).Elem() {
-		return fmt.Errorf("command type mismatch")
+	concreteType := reflect.TypeOf(concretePtr)
+	if concreteType == nil || !isStructPtr(concreteType) {
+		return fmt.Errorf("expected a pointer to a struct, got %T", concretePtr)
 	}
 
-	// start from the third argument as the first two are always `ctx` and `cmd`
-	for i := 2; i < handlerType.NumIn(); i++ {
-		if err := b.validateDependency(handlerType.In(i)); err != nil {
-			return err
-		}
+	if !concreteType.Implements(ifaceType) {
+		return fmt.Errorf("%s does not implement %s", concreteType.String(), ifaceType.String())
 	}
 
-	b.handlers[cmdType] = handler
+	structType := concreteType.Elem()
 
-	return nil
+	fnType := reflect.FuncOf([]reflect.Type{structType}, []reflect.Type{ifaceType, typeError}, false)
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		inst := reflect.New(structType)
+		inst.Elem().Set(args[0])
+
+		return []reflect.Value{inst, reflect.Zero(typeError)}
+	})
+
+	return b.registerProvider(fn.Interface(), false, opts)
 }
 
-// Invoke runs an associated command handler.
-func (b *Van) Invoke(ctx context.Context, cmd interface{}) error {
-	cmdType := reflect.TypeOf(cmd)
-	if cmdType.Kind() != reflect.Ptr {
-		return fmt.Errorf("cmd must be a pointer to a struct")
+// ProvideFromContext registers a provider that resolves the dependency of the interface type by reading
+// the value stored in the context under the given key, e.g. the authenticated user or the request ID.
+// The interface type is given as a typed nil pointer to the interface, e.g. (*User)(nil). Resolution fails
+// if the context has no value for the key, or the value does not implement the interface. The value is read
+// from the context of every call, so singleton providers cannot depend on it.
+func (b *Van) ProvideFromContext(ifacePtr interface{}, key interface{}, opts ...ProviderOption) {
+	if err := b.registerContextValue(ifacePtr, key, opts); err != nil {
+		panic(err)
 	}
+}
 
-	cmdType = cmdType.Elem()
-	if cmdType.Kind() != reflect.Struct {
-		return fmt.Errorf("cmd must be a pointer to a struct")
+func (b *Van) registerContextValue(ifacePtr interface{}, key interface{}, opts []ProviderOption) error {
+	ifaceType, err := interfaceOf(ifacePtr)
+	if err != nil {
+		return err
 	}
 
-	handler, ok := b.handlers[cmdType]
-	if !ok {
-		return fmt.Errorf("no handlers found for type %s", cmdType.String())
-	}
+	fnType := reflect.FuncOf([]reflect.Type{typeContext}, []reflect.Type{ifaceType, typeError}, false)
+	fn := reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		value := args[0].Interface().(context.Context).Value(key)
 
-	var args [maxArgs]reflect.Value
+		if value == nil {
+			err := fmt.Errorf("no value for key %v in the context", key)
+			return []reflect.Value{reflect.Zero(ifaceType), reflect.ValueOf(&err).Elem()}
+		}
 
-	handlerType := reflect.TypeOf(handler)
+		if !reflect.TypeOf(value).Implements(ifaceType) {
+			err := fmt.Errorf("context value %T does not implement %s", value, ifaceType.String())
+			return []reflect.Value{reflect.Zero(ifaceType), reflect.ValueOf(&err).Elem()}
+		}
 
-	numIn := handlerType.NumIn()
+		return []reflect.Value{reflect.ValueOf(value), reflect.Zero(typeError)}
+	})
 
-	if numIn > len(args) {
-		return fmt.Errorf("too many dependencies for handler %s", handlerType.String())
+	return b.registerProvider(fn.Interface(), false, opts)
+}
+
+// ProvideLazy registers a provider of the interface, which is passed as a nil pointer, e.g. (*Search)(nil),
+// that is produced by the factory the first time the dependency is needed, so that the subsystems that are
+// expensive to even set up do not slow down the startup if they are never used. The factory is called at most
+// once, and the provider it returns is then used the same way as the one registered with Provide.
+// Since the provider is not known upfront, its signature and the presence of the providers for its dependencies
+// are only checked on the first resolution, which fails if they are wrong. For the same reason, the singleton
+// providers must not depend on lazy providers that take Context.
+func (b *Van) ProvideLazy(ifacePtr interface{}, factory func() ProviderFunc, opts ...ProviderOption) {
+	if err := b.registerLazy(ifacePtr, factory, opts); err != nil {
+		panic(err)
 	}
+}
 
-	err := b.resolve(ctx, cmd, handlerType, args[:numIn])
+func (b *Van) registerLazy(ifacePtr interface{}, factory func() ProviderFunc, opts []ProviderOption) error {
+	ifaceType, err := interfaceOf(ifacePtr)
 	if err != nil {
 		return err
 	}
 
-	ret := reflect.ValueOf(handler).Call(args[:numIn])
+	// the placeholder is never called, but it keeps the introspection of the providers uniform
+	fnType := reflect.FuncOf(nil, []reflect.Type{ifaceType, typeError}, false)
+	fn := reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		panic("van: the placeholder of a lazy provider must not be called")
+	})
 
-	return toError(ret[0])
+	opts = append(opts, func(p *providerOpts) {
+		p.lazy = &lazyProvider{factory: factory}
+	})
+
+	return b.registerProvider(fn.Interface(), false, opts)
 }
 
-// Subscribe registers a new handler for the given command type. There can be any number of handlers per event.
+// ProvideNamed registers a type constructor under the given name, along with the default one if there is any.
+// Named dependencies are requested with the `van:"name"` tag on the fields of dependency structs, while
+// the untagged fields and function arguments are resolved with the default provider, unless another one is
+// selected for the call with WithNamedSelection.
 // It is expected to be called during the app startup phase as it performs the run time type checking and
 // panics if an incorrect function type is provided.
-func (b *Van) Subscribe(event interface{}, listeners ...ListenerFunc) {
-	for i := range listeners {
-		err := b.registerListener(event, listeners[i])
-		if err != nil {
-			panic(err)
-		}
+func (b *Van) ProvideNamed(name string, provider ProviderFunc, opts ...ProviderOption) {
+	opts = append(opts, Named(name))
+
+	if err := b.registerProvider(provider, false, opts); err != nil {
+		panic(err)
 	}
 }
 
-func (b *Van) registerListener(event interface{}, listener ListenerFunc) error {
-	eventType := reflect.TypeOf(event)
-	if eventType.Kind() != reflect.Struct {
-		return fmt.Errorf("event must be a struct, got %s", eventType.String())
+// ProvideScoped registers a type constructor whose instance is shared within a single call, i.e. it is called
+// at most once per Invoke, Exec or a published event, and the instance is discarded once the call is complete.
+// Outside of a call, e.g. with Resolve, a new instance is constructed every time. Since the instances are bound
+// to the call, scoped providers are treated as taking Context, and singletons cannot depend on them.
+// It is expected to be called during the app startup phase as it performs the run time type checking and
+// panics if an incorrect function type is provided.
+func (b *Van) ProvideScoped(provider ProviderFunc, opts ...ProviderOption) {
+	opts = append(opts, func(p *providerOpts) {
+		p.scoped = true
+		p.takesContext = true
+	})
+
+	if err := b.registerProvider(provider, false, opts); err != nil {
+		panic(err)
 	}
+}
 
-	listenerType := reflect.TypeOf(listener)
-	if err := validateListenerSignature(listenerType); err != nil {
-		return err
+// ProvideGroup adds a type constructor to the group of providers of the same interface. A dependency
+// declared as a slice of the interface, e.g. []HealthChecker, receives the instances constructed by all
+// providers of the group, in the order of registration. Group providers do not affect the resolution of
+// the interface itself. A slice dependency of a group without providers is resolved to an empty slice.
+// It is expected to be called during the app startup phase as it performs the run time type checking and
+// panics if an incorrect function type is provided.
+func (b *Van) ProvideGroup(provider ProviderFunc, opts ...ProviderOption) {
+	opts = append(opts, func(p *providerOpts) {
+		p.group = true
+	})
+
+	if err := b.registerProvider(provider, false, opts); err != nil {
+		panic(err)
+	}
+}
+
+// ProvideWithCleanup registers a singleton type constructor that returns a cleanup function along with
+// the instance, i.e. func(deps...) (Iface, func(), error). The cleanup functions of the constructed instances
+// are called by Shutdown. The cleanup function is ignored if the provider returns an error.
+// It is expected to be called during the app startup phase as it performs the run time type checking and
+// panics if an incorrect function type is provided.
+func (b *Van) ProvideWithCleanup(provider ProviderFunc, opts ...ProviderOption) {
+	opts = append(opts, func(p *providerOpts) {
+		p.cleanup = true
+	})
+
+	if err := b.registerProvider(provider, true, opts); err != nil {
+		panic(err)
 	}
+}
 
-	if eventType != listenerType.In(1) {
-		return fmt.Errorf("event type mismatch")
+// Override replaces the registered provider of the same type, which is mostly useful for swapping real
+// dependencies for mocks in tests. The new provider keeps the lifetime of the replaced one, and the cached
+// singleton instance is dropped, along with its concrete type. However, the instances that have already been
+// constructed with the replaced provider as a dependency are kept. It panics if there is no provider to replace.
+func (b *Van) Override(provider ProviderFunc, opts ...ProviderOption) {
+	if err := b.overrideProvider(provider, opts); err != nil {
+		panic(err)
 	}
+}
 
-	// start from the third argument as the first two are always `ctx` and `event`
-	for i := 2; i < listenerType.NumIn(); i++ {
-		if err := b.validateDependency(listenerType.In(i)); err != nil {
-			return err
-		}
+func (b *Van) overrideProvider(provider ProviderFunc, opts []ProviderOption) error {
+	providerType := reflect.TypeOf(provider)
+	if err := validateProviderSignature(providerType); err != nil {
+		return err
 	}
 
-	if _, ok := b.listeners[eventType]; !ok {
-		b.listeners[eventType] = make([]HandlerFunc, 0)
+	// apply the options to a scratch copy to find out the name of the provider
+	p := &providerOpts{}
+	for _, opt := range opts {
+		opt(p)
 	}
 
-	b.listeners[eventType] = append(b.listeners[eventType], listener)
+	key := providerKey{typ: providerType.Out(0), name: p.name}
 
-	return nil
+	existing, ok := b.provider(key)
+	if !ok {
+		return fmt.Errorf("%w for type %s", ErrProviderNotFound, key.String())
+	}
+
+	return b.registerProvider(provider, existing.singleton, opts)
 }
 
-// Publish sends an event to the bus. This is a fire-and-forget non-blocking operation.
-// Each listener will be called in a separate goroutine, and they can fail independently.
-// The error is never propagated back to the publisher, and should be handled by the listener itself.
-func (b *Van) Publish(event interface{}) error {
-	eventType := reflect.TypeOf(event)
-	if eventType.Kind() != reflect.Struct {
-		return fmt.Errorf("event must be a a struct, got %s", eventType.Name())
+// RemoveProvider removes the default provider of the type, which is passed as a nil pointer to the type,
+// e.g. (*Logger)(nil) or (**Config)(nil). It panics if the argument is not a pointer to a provided type.
+func (b *Van) RemoveProvider(typePtr interface{}) {
+	t, err := providedTypeOf(typePtr)
+	if err != nil {
+		panic(err)
 	}
 
-	b.wg.Add(1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	go func() {
-		defer b.wg.Done()
-		b.processEvent(event)
-	}()
+	key := providerKey{typ: t}
 
-	return nil
+	if p, ok := b.providers[key]; ok {
+		delete(b.providers, key)
+		b.removeConcrete(p)
+	}
 }
 
-func (b *Van) processEvent(event interface{}) {
-	eventType := reflect.TypeOf(event)
+func (b *Van) registerProvider(provider ProviderFunc, signleton bool, opts []ProviderOption) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	listeners, ok := b.listeners[eventType]
-	if !ok || len(listeners) == 0 {
-		return
+	p, err := b.checkProvider(provider, signleton, opts)
+	if err != nil {
+		return err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	retType := reflect.TypeOf(provider).Out(0)
 
-	for i := range listeners {
-		typ := reflect.TypeOf(listeners[i])
+	if p.autoClose {
+		b.hasAutoClose.Store(true)
+	}
 
-		var args [maxArgs]reflect.Value
+	if p.scoped {
+		b.hasScoped.Store(true)
+	}
 
-		numIn := typ.NumIn()
+	if p.instance != nil {
+		b.addConcrete(p.instance)
+	}
 
-		if numIn > len(args) {
-			log.Printf("van: too many dependencies for listener %s", typ.String())
-			continue
-		}
+	if p.group {
+		b.groups[retType] = append(b.groups[retType], p)
+		return nil
+	}
 
-		if numIn > 0 {
-			err := b.resolve(ctx, event, typ, args[:numIn])
-			if err != nil {
-				log.Printf("van: failed to resolve dependencies for %s: %s", typ.String(), err)
-				continue
-			}
-		}
+	key := providerKey{typ: retType, name: p.name}
+	replaced, ok := b.providers[key]
+	b.providers[key] = p
 
-		reflect.ValueOf(listeners[i]).Call(args[:numIn])
+	if ok {
+		b.removeConcrete(replaced)
 	}
+
+	return nil
 }
 
-// Exec executes the given function inside the dependency injector.
-func (b *Van) Exec(ctx context.Context, fn interface{}) error {
-	funcType := reflect.TypeOf(fn)
+// checkProvider validates the provider and returns its options without registering it. It must be called with
+// the lock held.
+func (b *Van) checkProvider(provider ProviderFunc, signleton bool, opts []ProviderOption) (*providerOpts, error) {
+	p := &providerOpts{
+		fn:        provider,
+		singleton: signleton,
+	}
 
-	if err := validateExecLambdaSignature(funcType); err != nil {
-		return err
+	if signleton {
+		p.building = make(chan struct{}, 1)
 	}
 
-	for i := 0; i < funcType.NumIn(); i++ {
-		if err := b.validateDependency(funcType.In(i)); err != nil {
-			return err
-		}
+	for _, opt := range opts {
+		opt(p)
 	}
 
-	var args [maxArgs]reflect.Value
+	validate := validateProviderSignature
+	if p.cleanup {
+		validate = validateCleanupProviderSignature
+	}
 
-	numIn := funcType.NumIn()
+	providerType := reflect.TypeOf(provider)
+	if err := validate(providerType); err != nil {
+		return nil, err
+	}
+
+	if p.autoClose {
+		if signleton {
+			return nil, fmt.Errorf("singleton providers cannot be auto-closed")
+		}
 
-	if numIn > len(args) {
-		return fmt.Errorf("too many dependencies for function %s", funcType.String())
+		p.usesAutoClose = true
 	}
 
-	err := b.resolve(ctx, nil, funcType, args[:numIn])
-	if err != nil {
-		return err
+	retType := providerType.Out(0)
+
+	if p.group && retType.Kind() != reflect.Interface {
+		return nil, newSignatureError(providerType, -1, "group providers must return an interface, got %s", retType.String())
 	}
 
-	ret := reflect.ValueOf(fn).Call(args[:numIn])
+	for i := 0; i < providerType.NumIn(); i++ {
+		inType := providerType.In(i)
 
-	return toError(ret[0])
-}
+		if inType == retType {
+			return nil, fmt.Errorf("provider function has a dependency of the same type")
+		}
 
-func (b *Van) resolve(ctx context.Context, cmd interface{}, funcType reflect.Type, args []reflect.Value) error {
-	for i := 0; i < funcType.NumIn(); i++ {
-		argType := funcType.In(i)
+		if err := b.validateRegisteredDependency(inType); err != nil {
+			return nil, err
+		}
 
-		switch {
-		case i == 0 && argType == typeContext:
-			args[i] = reflect.ValueOf(ctx)
-		case i == 1 && argType == reflect.TypeOf(cmd):
-			args[i] = reflect.ValueOf(cmd)
-		case argType == typeVan:
-			args[i] = reflect.ValueOf(b)
-		case argType.Kind() == reflect.Interface:
-			instance, err := b.new(ctx, argType)
-			if err != nil {
-				return err
+		// singletons receive the context of the call that constructs them, which does not
+		// make the providers that depend on them context-dependent
+		if inType == typeContext && !signleton {
+			p.takesContext = true
+		}
+
+		if pp, ok := b.providers[providerKey{typ: inType}]; ok && pp.takesContext {
+			if signleton {
+				return nil, fmt.Errorf("singleton providers cannot depend on providers that take Context")
 			}
 
-			args[i] = instance
-		case argType.Kind() == reflect.Struct:
-			value, err := b.buildStruct(ctx, argType)
-			if err != nil {
-				return err
+			p.takesContext = true
+		}
+
+		if pp, ok := b.providers[providerKey{typ: inType}]; ok && pp.usesAutoClose {
+			if signleton {
+				return nil, fmt.Errorf("singleton providers cannot depend on auto-closed providers")
 			}
 
-			args[i] = value
-		default:
+			p.usesAutoClose = true
 		}
 	}
 
+	return p, nil
+}
+
+// Handle registers a handler for the given command type. There can be only one handler per command,
+// so it panics if the command already has one, unless it is replaced on purpose with ReplaceHandler.
+// The behaviour of the handler can be adjusted with handler options, such as Idempotent. Handlers of commands
+// without fields, e.g. FlushCache{}, may omit the command argument, i.e. func(ctx, deps...) error, and any
+// handler may omit the context, i.e. func(cmd, deps...) error.
+// It is expected to be called during the app startup phase as it performs the run time type checking and
+// panics if an incorrect function type is provided.
+func (b *Van) Handle(cmd interface{}, handler HandlerFunc, opts ...HandlerOption) {
+	if err := b.registerHandler(cmd, handler, opts); err != nil {
+		panic(err)
+	}
+}
+
+// TryHandle registers a handler, same as Handle, but returns the error instead of panicking.
+// It is meant for the handlers registered at run time, e.g. by plugins.
+func (b *Van) TryHandle(cmd interface{}, handler HandlerFunc, opts ...HandlerOption) error {
+	return b.registerHandler(cmd, handler, opts)
+}
+
+// CheckHandler runs the same checks as Handle, including the presence of the providers for the dependencies
+// and of another handler of the command, but does not register the handler. It is meant for linters and code
+// generators doing a dry run of the wiring.
+func (b *Van) CheckHandler(cmd interface{}, handler HandlerFunc) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, err := b.checkHandlers(cmd, []HandlerFunc{handler}, false)
+
+	return err
+}
+
+func (b *Van) registerHandler(cmd interface{}, handler HandlerFunc, opts []HandlerOption) error {
+	return b.registerHandlers(cmd, []HandlerFunc{handler}, opts, false)
+}
+
+// ReplaceHandler replaces the handler of the given command type, e.g. to swap the handler of a shared
+// command in a test. The options of the replaced handler are not carried over. It panics if the command
+// has no handler to replace, or if an incorrect function type is provided.
+func (b *Van) ReplaceHandler(cmd interface{}, handler HandlerFunc, opts ...HandlerOption) {
+	if err := b.registerHandlers(cmd, []HandlerFunc{handler}, opts, true); err != nil {
+		panic(err)
+	}
+}
+
+// HandlePipeline registers an ordered pipeline of handlers for the given command type, e.g. to validate,
+// authorize and execute the command in separate steps. Invoke calls the handlers one after another,
+// resolving the dependencies of each, and stops at the first error. Same as Handle, it panics if the command
+// already has a handler.
+// If the handlers return values, the value of the last one is returned by InvokeResult.
+// It panics if any of the handlers has an incorrect function type.
+func (b *Van) HandlePipeline(cmd interface{}, handlers ...HandlerFunc) {
+	if len(handlers) == 0 {
+		panic(fmt.Errorf("pipeline must have at least one handler"))
+	}
+
+	if err := b.registerHandlers(cmd, handlers, nil, false); err != nil {
+		panic(err)
+	}
+}
+
+// registerHandlers stores the handler, or the pipeline of handlers, of the command. Unless replace is set,
+// the command must not have a handler yet. Otherwise, it must have one.
+func (b *Van) registerHandlers(cmd interface{}, handlers []HandlerFunc, opts []HandlerOption, replace bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cmdType, err := b.checkHandlers(cmd, handlers, replace)
+	if err != nil {
+		return err
+	}
+
+	h := &handlerOpts{fn: handlers[0]}
+	if len(handlers) > 1 {
+		h.pipeline = handlers
+	}
+
+	b.addHandler(cmdType, h, opts)
+
+	return nil
+}
+
+// checkHandlers validates the handlers of the command without registering them and returns the command type.
+// It must be called with the lock held.
+func (b *Van) checkHandlers(cmd interface{}, handlers []HandlerFunc, replace bool) (reflect.Type, error) {
+	cmdType := reflect.TypeOf(cmd)
+	if cmdType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cmd must be a struct, got %s", cmdType.String())
+	}
+
+	for _, handler := range handlers {
+		handlerType := reflect.TypeOf(handler)
+
+		// handlers of commands without fields may omit the command argument
+		if cmdType.NumField() == 0 && handlerType.Kind() == reflect.Func && !takesCommand(handlerType, cmdType) {
+			if err := validateParameterlessHandlerSignature(handlerType); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if err := validateHandlerSignature(handlerType); err != nil {
+			return nil, err
+		}
+
+		if cmdType != handlerType.In(messageIndex(handlerType)).Elem() {
+			return nil, fmt.Errorf("command type mismatch")
+		}
+	}
+
+	for _, handler := range handlers {
+		handlerType := reflect.TypeOf(handler)
+
+		// skip `ctx` and `cmd`, if the handler takes it
+		for i := handlerDepsStart(handlerType, cmdType); i < handlerType.NumIn(); i++ {
+			if err := b.validateRegisteredDependency(handlerType.In(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch _, ok := b.handlers[cmdType]; {
+	case ok && !replace:
+		return nil, fmt.Errorf("handler already registered for type %s", cmdType.String())
+	case !ok && replace:
+		return nil, fmt.Errorf("%w for type %s", ErrHandlerNotFound, cmdType.String())
+	}
+
+	return cmdType, nil
+}
+
+// addHandler applies the options and stores the handler. It must be called with the lock held.
+func (b *Van) addHandler(cmdType reflect.Type, h *handlerOpts, opts []HandlerOption) {
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	b.buildChain(h)
+
+	b.handlers[cmdType] = h
+}
+
+// HandleWithTimeout registers a handler, same as Handle, that is given at most d to process the command.
+// The handler and its providers receive a context that is cancelled once the timeout is exceeded. The handler
+// still runs in the goroutine of the caller, so Invoke returns once the handler returns. If the handler ignores
+// the context and succeeds after the deadline, Invoke returns an error wrapping context.DeadlineExceeded.
+func (b *Van) HandleWithTimeout(cmd interface{}, handler HandlerFunc, d time.Duration, opts ...HandlerOption) {
+	b.Handle(cmd, handler, append(opts, WithTimeout(d))...)
+}
+
+// HandleWithRetry registers a handler, same as Handle, that is called again when it fails with an error that
+// the policy considers retryable, up to policy.MaxAttempts times. The dependencies are resolved anew for every
+// attempt, so the transient and scoped providers are called again, while the singletons are reused.
+// The backoff between the attempts is interrupted once the context is cancelled, in which case Invoke returns
+// the error of the last attempt. When combined with WithTimeout, the timeout applies to all attempts together.
+func (b *Van) HandleWithRetry(cmd interface{}, handler HandlerFunc, policy RetryPolicy, opts ...HandlerOption) {
+	b.Handle(cmd, handler, append(opts, WithRetryPolicy(policy))...)
+}
+
+// Invoke runs an associated command handler.
+func (b *Van) Invoke(ctx context.Context, cmd interface{}) error {
+	// the result slot of InvokeResult belongs to the command it was called with, not to the commands
+	// invoked by its handler with the same context
+	if resultFromContext(ctx) != nil {
+		ctx = withResult(ctx, nil)
+	}
+
+	return b.dispatch(ctx, cmd)
+}
+
+// dispatch runs an associated command handler, storing its value in the result slot of the context, if any.
+func (b *Van) dispatch(ctx context.Context, cmd interface{}) error {
+	cmdType := reflect.TypeOf(cmd)
+	if cmdType.Kind() != reflect.Ptr {
+		return fmt.Errorf("cmd must be a pointer to a struct")
+	}
+
+	cmdType = cmdType.Elem()
+	if cmdType.Kind() != reflect.Struct {
+		return fmt.Errorf("cmd must be a pointer to a struct")
+	}
+
+	if b.recorder.record(cmd) {
+		return nil
+	}
+
+	b.mu.RLock()
+	h, ok := b.handlers[cmdType]
+	b.mu.RUnlock()
+
+	if !ok {
+		if b.unhandledCommand != nil {
+			return b.unhandledCommand(ctx, cmd)
+		}
+
+		return fmt.Errorf("%w for type %s", ErrHandlerNotFound, cmdType.String())
+	}
+
+	if b.tracer != nil {
+		var end func(error)
+
+		ctx, end = b.tracer.StartSpan(ctx, "invoke "+cmdType.String())
+
+		err := b.invoke(ctx, h, cmd)
+		end(err)
+
+		return err
+	}
+
+	return b.invoke(ctx, h, cmd)
+}
+
+// invoke runs the command through the middleware chain of the handler, if there is one.
+func (b *Van) invoke(ctx context.Context, h *handlerOpts, cmd interface{}) error {
+	if h.chain != nil {
+		return h.chain(ctx, cmd)
+	}
+
+	return b.handle(ctx, h, cmd)
+}
+
+// handle runs the command through the handler, taking care of the handler options.
+func (b *Van) handle(ctx context.Context, h *handlerOpts, cmd interface{}) error {
+	var key, value interface{}
+
+	if h.idempotency != nil {
+		key, value = h.idempotency.entry(cmd)
+
+		if h.idempotency.isDuplicate(key, value, b.now()) {
+			return nil
+		}
+	}
+
+	var cl *closers
+
+	if b.hasAutoClose.Load() {
+		cl = &closers{}
+		ctx = withClosers(ctx, cl)
+
+		// the dependencies are closed below, so this only matters if the handler panics
+		defer func() { _ = cl.close() }()
+	}
+
+	if b.hasScoped.Load() {
+		ctx = withScopedCache(ctx, &scopedCache{})
+	}
+
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	var start time.Time
+	if b.hooks.OnHandle != nil {
+		start = time.Now()
+	}
+
+	var err error
+
+	if h.retry != nil {
+		err = b.callWithRetry(ctx, h, cmd)
+	} else {
+		err = b.call(ctx, h, cmd)
+	}
+
+	if b.hooks.OnHandle != nil {
+		b.hooks.OnHandle(cmd, time.Since(start), err)
+	}
+
+	// the handler might have ignored the context and finished successfully after the deadline
+	if err == nil && h.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("handler of %s timed out after %s: %w", reflect.TypeOf(cmd).Elem().String(), h.timeout, context.DeadlineExceeded)
+	}
+
+	if closeErr := cl.close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && h.idempotency != nil {
+		h.idempotency.seen.add(key, value, b.now())
+	}
+
+	return err
+}
+
+// callWithRetry runs the handler according to its retry policy, returning the error of the last attempt.
+func (b *Van) callWithRetry(ctx context.Context, h *handlerOpts, cmd interface{}) error {
+	for attempt := 1; ; attempt++ {
+		attemptCtx := ctx
+		if attempt > 1 && b.hasScoped.Load() {
+			attemptCtx = withScopedCache(ctx, &scopedCache{})
+		}
+
+		err := b.call(attemptCtx, h, cmd)
+		if err == nil || attempt >= h.retry.MaxAttempts {
+			return err
+		}
+
+		if h.retry.Retryable != nil && !h.retry.Retryable(err) {
+			return err
+		}
+
+		if sleepContext(ctx, h.retry.Backoff, attempt) != nil {
+			return err
+		}
+	}
+}
+
+// call runs the handler, recovering from its panic if the bus is created with WithRecover.
+func (b *Van) call(ctx context.Context, h *handlerOpts, cmd interface{}) (err error) {
+	if b.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = b.panicError(r, "handler", reflect.TypeOf(cmd).Elem(), "")
+			}
+		}()
+	}
+
+	if h.typed != nil {
+		return h.typed(ctx, b, cmd)
+	}
+
+	if h.pipeline != nil {
+		for _, fn := range h.pipeline {
+			if err := b.callHandler(ctx, fn, cmd); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return b.callHandler(ctx, h.fn, cmd)
+}
+
+// callHandler resolves the dependencies of the handler and calls it.
+func (b *Van) callHandler(ctx context.Context, handler HandlerFunc, cmd interface{}) error {
+	var stack [maxArgs]reflect.Value
+
+	handlerType := reflect.TypeOf(handler)
+
+	numIn := handlerType.NumIn()
+	args := argsBuffer(stack[:], numIn)
+
+	var scope *Scope
+
+	if takesScope(handlerType) {
+		scope = newScope(ctx, b)
+		ctx = withScope(ctx, scope)
+	}
+
+	// handlers without dependencies only take the context and the command, both already at hand,
+	// so there is nothing to resolve and no timeout to bound the resolution with
+	if numIn == 2 && handlerType.In(1) == reflect.TypeOf(cmd) {
+		args[0] = reflect.ValueOf(ctx)
+		args[1] = reflect.ValueOf(cmd)
+	} else {
+		release, err := b.resolveWithTimeout(ctx, cmd, handlerType, args)
+		if err != nil {
+			return err
+		}
+
+		defer release()
+	}
+
+	ret := reflect.ValueOf(handler).Call(args)
+	err := toError(ret[len(ret)-1])
+
+	if len(ret) == 2 {
+		if r := resultFromContext(ctx); r != nil {
+			r.value = ret[0].Interface()
+		}
+	}
+
+	if scope != nil {
+		if scopeErr := scope.wait(); err == nil {
+			err = scopeErr
+		}
+	}
+
+	return err
+}
+
+// InvokeCopy runs an associated command handler on a shallow copy of the command, leaving the original
+// command untouched. This makes it safe to reuse the same command concurrently, e.g. as a template.
+// Since the copy is shallow, the handler still shares the maps, slices and pointers with the original.
+func (b *Van) InvokeCopy(ctx context.Context, cmd interface{}) error {
+	value := reflect.ValueOf(cmd)
+	if !isStructPtr(value.Type()) || value.IsNil() {
+		return fmt.Errorf("cmd must be a pointer to a struct")
+	}
+
+	cmdCopy := reflect.New(value.Type().Elem())
+	cmdCopy.Elem().Set(value.Elem())
+
+	return b.Invoke(ctx, cmdCopy.Interface())
+}
+
+// InvokeAsync runs an associated command handler in the background, same as Invoke, including the resolution
+// of its dependencies with the given context. The returned channel receives the result of the handler once it
+// is done, and can be ignored by the callers that do not need to wait for it. The call is tracked by Wait.
+// The handler may still be running when InvokeAsync returns, so the command must not be modified until then.
+func (b *Van) InvokeAsync(ctx context.Context, cmd interface{}) <-chan error {
+	done := make(chan error, 1)
+
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+		done <- b.Invoke(ctx, cmd)
+	}()
+
+	return done
+}
+
+// InvokeValue runs an associated command handler, same as Invoke, but accepts the command as a struct value
+// rather than a pointer. The handler receives a pointer to a copy of the command, so any changes it makes to
+// the command are not visible to the caller. It is meant for read-only commands.
+func (b *Van) InvokeValue(ctx context.Context, cmd interface{}) error {
+	value := reflect.ValueOf(cmd)
+	if !value.IsValid() || value.Kind() != reflect.Struct {
+		return fmt.Errorf("cmd must be a struct")
+	}
+
+	cmdPtr := reflect.New(value.Type())
+	cmdPtr.Elem().Set(value)
+
+	return b.Invoke(ctx, cmdPtr.Interface())
+}
+
+// Subscribe registers a new handler for the given command type. There can be any number of handlers per event.
+// Listeners may omit the context, i.e. func(event, deps...).
+// It is expected to be called during the app startup phase as it performs the run time type checking and
+// panics if an incorrect function type is provided.
+//
+// Listener options, such as WithRetry and WithDeadLetter, can be passed along with the listeners and apply to
+// all of them. Listeners may return an error. A failure of a listener is either a returned error, a recovered
+// panic or a failure to resolve its dependencies, and does not prevent the other listeners from being called.
+// Each failed attempt is retried according to WithRetry, and once the attempts are exhausted, the event and
+// the last error are passed to the WithDeadLetter sink.
+//
+// The returned function unsubscribes the listeners registered by the call. It is safe to call concurrently
+// with Publish, but the events that are already being processed may still reach the removed listeners.
+func (b *Van) Subscribe(event interface{}, listeners ...ListenerFunc) (unsubscribe func()) {
+	unsubscribe, err := b.subscribe(eventType(event), listeners)
+	if err != nil {
+		panic(err)
+	}
+
+	return unsubscribe
+}
+
+// TrySubscribe registers the listeners, same as Subscribe, but returns the error instead of panicking.
+// It is meant for the listeners registered at run time, e.g. by plugins. If any of the listeners is invalid,
+// none of them are registered.
+func (b *Van) TrySubscribe(event interface{}, listeners ...ListenerFunc) (unsubscribe func(), err error) {
+	return b.subscribe(eventType(event), listeners)
+}
+
+// SubscribeAll registers catch-all listeners that receive every published event, regardless of its type,
+// e.g. for audit logging. The listeners take the event as interface{}, i.e. func(ctx, event interface{}, deps...),
+// and may have dependencies and options, same as the ones registered with Subscribe. They are called after
+// the listeners of the specific event type. It panics if an incorrect function type is provided.
+func (b *Van) SubscribeAll(listeners ...ListenerFunc) (unsubscribe func()) {
+	unsubscribe, err := b.subscribe(typeAny, listeners)
+	if err != nil {
+		panic(err)
+	}
+
+	return unsubscribe
+}
+
+func (b *Van) subscribe(eventType reflect.Type, listeners []ListenerFunc) (func(), error) {
+	var opts []ListenerOption
+
+	fns := make([]ListenerFunc, 0, len(listeners))
+
+	for i := range listeners {
+		if opt, ok := listeners[i].(ListenerOption); ok {
+			opts = append(opts, opt)
+			continue
+		}
+
+		fns = append(fns, listeners[i])
+	}
+
+	registered := make([]*listenerOpts, 0, len(fns))
+
+	unsubscribe := func() {
+		for _, l := range registered {
+			b.removeListener(eventType, l)
+		}
+	}
+
+	for i := range fns {
+		l, err := b.registerListener(eventType, fns[i], opts)
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+
+		registered = append(registered, l)
+	}
+
+	return unsubscribe, nil
+}
+
+// SubscribeWithPriority registers the listeners, same as Subscribe, with the given priority. The listeners
+// of an event are called in the order of descending priority, so that e.g. a cache is invalidated before the
+// notifications are sent. Listeners with the same priority are called in the order of registration.
+// Subscribe registers the listeners with the priority of zero.
+func (b *Van) SubscribeWithPriority(event interface{}, priority int, listeners ...ListenerFunc) (unsubscribe func()) {
+	return b.Subscribe(event, append(listeners, withPriority(priority))...)
+}
+
+// registerListener adds the listener of the event type, which is interface{} for the catch-all listeners.
+func (b *Van) registerListener(eventType reflect.Type, listener ListenerFunc, opts []ListenerOption) (*listenerOpts, error) {
+	if eventType == nil || eventType.Kind() != reflect.Struct && eventType != typeAny {
+		return nil, fmt.Errorf("event must be a struct value, unlike commands that are passed by pointer, got %v", eventType)
+	}
+
+	listenerType := reflect.TypeOf(listener)
+	if err := validateListenerSignature(listenerType); err != nil {
+		return nil, err
+	}
+
+	if eventType != listenerType.In(messageIndex(listenerType)) {
+		return nil, fmt.Errorf("event type mismatch")
+	}
+
+	l := &listenerOpts{fn: listener}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// skip `ctx`, if the listener takes it, and `event`
+	for i := messageIndex(listenerType) + 1; i < listenerType.NumIn(); i++ {
+		if err := b.validateRegisteredDependency(listenerType.In(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	// the slice is copied rather than modified in place, since it might be iterated by processEvent
+	current := b.listeners[eventType]
+
+	// listeners are kept sorted by priority, the ones with the same priority in the order of registration
+	pos := sort.Search(len(current), func(i int) bool {
+		return current[i].priority < l.priority
+	})
+
+	updated := make([]*listenerOpts, 0, len(current)+1)
+	updated = append(updated, current[:pos]...)
+	updated = append(updated, l)
+	updated = append(updated, current[pos:]...)
+
+	b.listeners[eventType] = updated
+
+	return l, nil
+}
+
+func (b *Van) removeListener(eventType reflect.Type, l *listenerOpts) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.listeners[eventType]
+	updated := make([]*listenerOpts, 0, len(current))
+
+	for _, other := range current {
+		if other != l {
+			updated = append(updated, other)
+		}
+	}
+
+	b.listeners[eventType] = updated
+}
+
+// Publish sends an event to the bus. This is a fire-and-forget non-blocking operation.
+// Each listener will be called in a separate goroutine, and they can fail independently.
+// The error is never propagated back to the publisher, and should be handled by the listener itself.
+func (b *Van) Publish(event interface{}) error {
+	return b.publish(context.Background(), event, b.logError, nil)
+}
+
+// publish processes the event in the background with the given context, passing the listener failures to the report
+// function. If wg is not nil, it tracks the processing in addition to the wait group of the bus.
+func (b *Van) publish(ctx context.Context, event interface{}, report func(error), wg *sync.WaitGroup) error {
+	event, err := eventValue(event)
+	if err != nil {
+		return err
+	}
+
+	if b.isDuplicateEvent(event) {
+		if b.hooks.OnDuplicateEvent != nil {
+			b.hooks.OnDuplicateEvent(event)
+		}
+
+		return nil
+	}
+
+	if b.recorder.record(event) {
+		return nil
+	}
+
+	b.wg.Add(1)
+
+	if wg != nil {
+		wg.Add(1)
+	}
+
+	go func() {
+		defer b.wg.Done()
+
+		if wg != nil {
+			defer wg.Done()
+		}
+
+		b.processEvent(ctx, event, report)
+	}()
+
+	return nil
+}
+
+// isDuplicateEvent reports whether the event has the same key as another one published within the dedup window.
+func (b *Van) isDuplicateEvent(event interface{}) bool {
+	if b.dedupSeen == nil {
+		return false
+	}
+
+	return !b.dedupSeen.addIfAbsent(b.dedupKey(event), nil, b.now())
+}
+
+// PublishSync sends an event to the bus and blocks until all listeners have processed it. Unlike Publish,
+// it returns the failures of all listeners joined into a single error, in the order of their registration.
+// Listener panics are recovered and returned as errors as well.
+//
+// The listeners receive a context derived from ctx. If ctx is cancelled before all listeners are done,
+// PublishSync returns ctx.Err() right away without waiting for the pending listeners, which keep running
+// in the background and are tracked by Wait. Their failures are then logged, same as with Publish.
+// Long-running listeners are expected to check ctx.Done() to stop early.
+func (b *Van) PublishSync(ctx context.Context, event interface{}) error {
+	event, err := eventValue(event)
+	if err != nil {
+		return err
+	}
+
+	if b.recorder.record(event) {
+		return nil
+	}
+
+	var (
+		mu       sync.Mutex
+		errs     []error
+		returned bool
+	)
+
+	report := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if returned {
+			b.logError(err)
+			return
+		}
+
+		errs = append(errs, err)
+	}
+
+	done := make(chan struct{})
+
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+		defer close(done)
+		b.processEvent(ctx, event, report)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		default:
+			mu.Lock()
+			returned = true
+			mu.Unlock()
+
+			return ctx.Err()
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// processEvent runs all listeners of the event one by one, followed by the catch-all listeners. Listeners that
+// fail, including the ones that panic, are skipped, and the failure is passed to the report function.
+func (b *Van) processEvent(ctx context.Context, event interface{}, report func(error)) {
+	eventType := reflect.TypeOf(event)
+
+	b.mu.RLock()
+	listeners := b.listeners[eventType]
+	catchAll := b.listeners[typeAny]
+	b.mu.RUnlock()
+
+	if len(listeners) == 0 && len(catchAll) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if b.hasScoped.Load() {
+		ctx = withScopedCache(ctx, &scopedCache{})
+	}
+
+	for i := range listeners {
+		b.runListener(ctx, event, listeners[i], report)
+	}
+
+	for i := range catchAll {
+		b.runListener(ctx, event, catchAll[i], report)
+	}
+}
+
+// callListener calls the listener, waiting for a free slot first if the global listener limit is set.
+func (b *Van) callListener(ctx context.Context, listener ListenerFunc, args []reflect.Value) error {
+	if b.listenerSlots != nil {
+		select {
+		case b.listenerSlots <- struct{}{}:
+			defer func() { <-b.listenerSlots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	atomic.AddInt32(&b.activeListeners, 1)
+	defer atomic.AddInt32(&b.activeListeners, -1)
+
+	ret := reflect.ValueOf(listener).Call(args)
+	if len(ret) == 0 {
+		return nil
+	}
+
+	return toError(ret[0])
+}
+
+func (b *Van) logError(err error) {
+	b.logger.Printf("van: %s", err)
+}
+
+// Exec executes the given function inside the dependency injector.
+func (b *Van) Exec(ctx context.Context, fn interface{}) error {
+	funcType := reflect.TypeOf(fn)
+
+	if err := validateExecLambdaSignature(funcType); err != nil {
+		return err
+	}
+
+	_, err := b.exec(ctx, fn, funcType)
+
+	return err
+}
+
+// exec resolves the dependencies and calls the function, whose last return value is an error.
+// It returns the values returned by the function, along with the error of either the function
+// or closing the dependencies. The returned values are nil if the dependencies cannot be resolved.
+func (b *Van) exec(ctx context.Context, fn interface{}, funcType reflect.Type) ([]reflect.Value, error) {
+	for i := 0; i < funcType.NumIn(); i++ {
+		if err := b.validateDependencyLocked(funcType.In(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	var stack [maxArgs]reflect.Value
+
+	numIn := funcType.NumIn()
+	args := argsBuffer(stack[:], numIn)
+
+	var cl *closers
+
+	if b.hasAutoClose.Load() {
+		cl = &closers{}
+		ctx = withClosers(ctx, cl)
+
+		// the dependencies are closed below, so this only matters if the resolution fails or the function panics
+		defer func() { _ = cl.close() }()
+	}
+
+	if b.hasScoped.Load() {
+		ctx = withScopedCache(ctx, &scopedCache{})
+	}
+
+	release, err := b.resolveWithTimeout(ctx, nil, funcType, args[:numIn])
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	ret := reflect.ValueOf(fn).Call(args[:numIn])
+	err = toError(ret[len(ret)-1])
+
+	if closeErr := cl.close(); err == nil {
+		err = closeErr
+	}
+
+	return ret, err
+}
+
+// Inject populates the interface fields of an existing struct with the dependencies from the bus.
+// The target must be a pointer to a struct. Unexported fields, fields of non-interface types, fields that
+// are already set, and fields tagged with `van:"-"` are left untouched. An error is returned if there is
+// no provider for any of the remaining fields.
+func (b *Van) Inject(ctx context.Context, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if !isStructPtr(value.Type()) || value.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer to a struct")
+	}
+
+	value = value.Elem()
+
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		if !field.IsExported() || field.Type.Kind() != reflect.Interface || field.Tag.Get("van") == "-" {
+			continue
+		}
+
+		name, optional := parseTag(field.Tag)
+
+		fieldValue, err := value.FieldByIndexErr(field.Index)
+		if err != nil || !fieldValue.IsNil() {
+			continue // embedded through a nil pointer, or already set
+		}
+
+		if optional && !b.hasProvider(field.Type, name) {
+			continue
+		}
+
+		b.mu.RLock()
+		err = b.validateNamedDependency(field.Type, name)
+		b.mu.RUnlock()
+
+		if err != nil {
+			return fmt.Errorf("failed to inject field %s: %w", field.Name, err)
+		}
+
+		instance, err := b.newNamed(ctx, field.Type, name)
+		if err != nil {
+			return fmt.Errorf("failed to inject field %s: %w", field.Name, err)
+		}
+
+		fieldValue.Set(instance)
+	}
+
+	return nil
+}
+
+// resolveWithTimeout resolves the function arguments, bounding the time spent on dependency construction
+// by the resolve timeout, if one is configured. The function itself receives the original context. The returned
+// function releases the context passed to the providers, and must be called once the function is complete.
+func (b *Van) resolveWithTimeout(ctx context.Context, cmd interface{}, funcType reflect.Type, args []reflect.Value) (func(), error) {
+	if b.resolveTimeout <= 0 {
+		return noRelease, b.resolve(ctx, cmd, funcType, args)
+	}
+
+	// the providers may keep the context they receive, so it is only cancelled early when the timeout fires,
+	// and otherwise stays valid until the call is complete
+	resolveCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(b.resolveTimeout, cancel)
+
+	err := b.resolve(resolveCtx, cmd, funcType, args)
+	timedOut := !timer.Stop()
+
+	if err != nil {
+		cancel()
+
+		if timedOut && ctx.Err() == nil {
+			return noRelease, fmt.Errorf("dependency resolution timed out after %s: %w", b.resolveTimeout, context.DeadlineExceeded)
+		}
+
+		return noRelease, err
+	}
+
+	if len(args) > 0 && funcType.In(0) == typeContext {
+		args[0] = reflect.ValueOf(ctx)
+	}
+
+	return cancel, nil
+}
+
+func noRelease() {}
+
+func (b *Van) resolve(ctx context.Context, cmd interface{}, funcType reflect.Type, args []reflect.Value) error {
+	// the command or event follows the context, which handlers and listeners may omit
+	msg := messageIndex(funcType)
+
+	// the arguments constructed by the providers, which are resolved concurrently in the parallel mode
+	var deferred []int
+
+	for i := 0; i < funcType.NumIn(); i++ {
+		argType := funcType.In(i)
+
+		switch {
+		case i == 0 && argType == typeContext:
+			args[i] = reflect.ValueOf(ctx)
+		case i == msg && cmd != nil && (argType == reflect.TypeOf(cmd) || argType == typeAny):
+			args[i] = reflect.ValueOf(cmd)
+		case argType == typeVan:
+			if b.strictBus {
+				return errStrictBus
+			}
+
+			args[i] = reflect.ValueOf(b)
+		case argType == typePublisher:
+			args[i] = reflect.ValueOf(Publisher(b))
+		case argType == typeDispatcher:
+			args[i] = reflect.ValueOf(Dispatcher(b))
+		case argType == typeScope:
+			scope := scopeFromContext(ctx)
+			if scope == nil {
+				return fmt.Errorf("*van.Scope is only available in command handlers")
+			}
+
+			args[i] = reflect.ValueOf(scope)
+		case isProvidedType(argType), isGroupType(argType), argType.Kind() == reflect.Struct:
+			if b.resolveSlots != nil {
+				deferred = append(deferred, i)
+				continue
+			}
+
+			value, err := b.resolveArg(ctx, argType)
+			if err != nil {
+				return err
+			}
+
+			args[i] = value
+		default:
+			return fmt.Errorf("unresolvable argument %d of kind %s", i, argType.Kind())
+		}
+	}
+
+	if len(deferred) > 0 {
+		return b.resolveParallel(ctx, funcType, args, deferred)
+	}
+
+	return nil
+}
+
+// resolveArg constructs the argument of the given type through the providers.
+func (b *Van) resolveArg(ctx context.Context, argType reflect.Type) (reflect.Value, error) {
+	switch {
+	case isProvidedType(argType):
+		return b.new(ctx, argType)
+	case isGroupType(argType):
+		return b.newGroup(ctx, argType)
+	}
+
+	if depType, ok := optionalType(argType); ok {
+		return b.buildOptional(ctx, argType, depType)
+	}
+
+	return b.buildStruct(ctx, argType)
+}
+
+// resolveParallel resolves the arguments at the given indices, each of them in its own goroutine as long as
+// there is a free worker slot, and in the calling goroutine otherwise, so that the nested resolutions never wait
+// for the slots held by their parents. The instances shared by the arguments are still constructed once, since
+// the singletons and the scoped instances are cached by the providers. A panic of a provider is propagated to
+// the calling goroutine, same as without the parallel mode.
+func (b *Van) resolveParallel(ctx context.Context, funcType reflect.Type, args []reflect.Value, indices []int) error {
+	if len(indices) == 1 {
+		value, err := b.resolveArg(ctx, funcType.In(indices[0]))
+		args[indices[0]] = value
+
+		return err
+	}
+
+	// the goroutines write into a separate slice, so that the arguments, which are usually allocated
+	// on the stack of the caller, do not escape to the heap
+	var (
+		wg     sync.WaitGroup
+		values = make([]reflect.Value, len(indices))
+		errs   = make([]error, len(indices))
+		panics = make([]interface{}, len(indices))
+	)
+
+	for n, i := range indices {
+		select {
+		case b.resolveSlots <- struct{}{}:
+			wg.Add(1)
+
+			go func(n int, argType reflect.Type) {
+				defer func() {
+					panics[n] = recover()
+					<-b.resolveSlots
+					wg.Done()
+				}()
+
+				values[n], errs[n] = b.resolveArg(ctx, argType)
+			}(n, funcType.In(i))
+		default:
+			values[n], errs[n] = b.resolveArg(ctx, funcType.In(i))
+		}
+	}
+
+	wg.Wait()
+
+	for n, i := range indices {
+		if panics[n] != nil {
+			panic(panics[n])
+		}
+
+		if errs[n] != nil {
+			return errs[n]
+		}
+
+		args[i] = values[n]
+	}
+
 	return nil
 }
 
+// buildStruct constructs the dependency struct, recursing into the nested dependency structs. The fields are
+// resolved in the order of declaration, and each provider is called at most once per struct, including the nested
+// structs and the transitive dependencies, so that the fields that share a dependency get the same instance.
 func (b *Van) buildStruct(ctx context.Context, structType reflect.Type) (reflect.Value, error) {
-	fields := reflect.VisibleFields(structType)
+	if structCacheFromContext(ctx) == nil {
+		ctx = withStructCache(ctx, &scopedCache{})
+	}
+
 	value := reflect.New(structType).Elem()
 
-	for _, field := range fields {
-		instance, err := b.new(ctx, field.Type)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			nested, err := b.buildStruct(ctx, field.Type)
+			if err != nil {
+				return reflect.ValueOf(nil), err
+			}
+
+			value.Field(i).Set(nested)
+
+			continue
+		}
+
+		name, optional := parseTag(field.Tag)
+		if optional && !b.hasProvider(field.Type, name) {
+			continue
+		}
+
+		instance, err := b.newNamed(ctx, field.Type, name)
 		if err != nil {
 			return reflect.ValueOf(nil), err
 		}
 
-		value.FieldByIndex(field.Index).Set(instance)
+		value.Field(i).Set(instance)
 	}
 
 	return value, nil
 }
 
+// new returns an instance of type t constructed by the default provider.
 func (b *Van) new(ctx context.Context, t reflect.Type) (reflect.Value, error) {
-	provider := b.providers[t]
+	return b.newNamed(ctx, t, "")
+}
+
+// newNamed returns an instance of type t constructed by the provider with the given name, passing it
+// through the dependency interceptor if there is one. Unnamed requests use the name selected in the context.
+func (b *Van) newNamed(ctx context.Context, t reflect.Type, name string) (reflect.Value, error) {
+	if name == "" {
+		name = selectedName(ctx, t)
+	}
+
+	inst, err := b.newInstance(ctx, providerKey{typ: t, name: name})
+	if err != nil {
+		return inst, err
+	}
+
+	return b.intercept(ctx, t, inst)
+}
+
+// intercept passes the instance of type t through the dependency interceptor if there is one.
+func (b *Van) intercept(ctx context.Context, t reflect.Type, inst reflect.Value) (reflect.Value, error) {
+	if b.interceptor == nil {
+		return inst, nil
+	}
+
+	replaced, err := b.interceptor(ctx, t, inst.Interface())
+	if err != nil {
+		return reflect.ValueOf(nil), fmt.Errorf("dependency %s rejected: %w", t.String(), err)
+	}
+
+	if replaced == nil || !reflect.TypeOf(replaced).AssignableTo(t) {
+		return reflect.ValueOf(nil), fmt.Errorf("interceptor returned %T, which is not assignable to %s", replaced, t.String())
+	}
+
+	return reflect.ValueOf(replaced), nil
+}
+
+func (b *Van) newInstance(ctx context.Context, key providerKey) (reflect.Value, error) {
+	provider, ok := b.provider(key)
+	if !ok {
+		if key.name == "" {
+			if inst, ok, err := b.concreteInstance(ctx, key.typ); ok {
+				return inst, err
+			}
+		}
+
+		return reflect.ValueOf(nil), fmt.Errorf("%w for type %s", ErrProviderNotFound, key.String())
+	}
+
+	return b.instantiate(ctx, key.typ, provider)
+}
 
+// instantiate returns an instance of type t constructed by the provider, or the cached instance of a singleton.
+func (b *Van) instantiate(ctx context.Context, t reflect.Type, provider *providerOpts) (reflect.Value, error) {
 	if provider.singleton {
+		// the lock of the singleton is already held by this call if the dependency is circular
+		if path, ok := constructingFromContext(ctx).cycle(t, provider); ok {
+			return reflect.ValueOf(nil), fmt.Errorf("circular singleton dependency: %s", path)
+		}
+
 		provider.RLock()
 
 		if provider.instance == nil {
 			provider.RUnlock()
 
-			return b.newSingleton(ctx, t)
+			return b.newSingleton(ctx, t, provider)
 		}
 
 		provider.RUnlock()
@@ -388,51 +1863,178 @@ func (b *Van) new(ctx context.Context, t reflect.Type) (reflect.Value, error) {
 		return reflect.ValueOf(provider.instance), nil
 	}
 
-	providerType := reflect.TypeOf(provider.fn)
+	cache := structCacheFromContext(ctx)
+	if provider.scoped {
+		cache = scopedCacheFromContext(ctx)
+	}
 
-	var args [maxArgs]reflect.Value
+	if inst, ok := cache.get(provider); ok {
+		return inst, nil
+	}
 
-	numIn := providerType.NumIn()
+	inst, err := b.construct(ctx, t, provider)
+	if err != nil {
+		return reflect.ValueOf(nil), err
+	}
 
-	if numIn > len(args) {
-		return reflect.ValueOf(nil), fmt.Errorf("too many dependencies for provider %s", providerType.String())
+	if provider.autoClose {
+		if closer, ok := inst.Interface().(io.Closer); ok {
+			closersFromContext(ctx).add(closer)
+		}
 	}
 
-	if numIn > 0 {
-		err := b.resolve(ctx, nil, providerType, args[:numIn])
-		if err != nil {
-			return reflect.ValueOf(nil), err
+	return cache.add(provider, inst), nil
+}
+
+type constructingKey struct{}
+
+// constructing is a link in the chain of singletons that are being constructed by the current call.
+// It is passed down through the context, so that a singleton that transitively depends on itself
+// is reported instead of deadlocking on its own lock.
+type constructing struct {
+	typ      reflect.Type
+	provider *providerOpts
+	parent   *constructing
+}
+
+func constructingFromContext(ctx context.Context) *constructing {
+	c, _ := ctx.Value(constructingKey{}).(*constructing)
+	return c
+}
+
+// cycle returns the path of the circular dependency if the provider is already being constructed.
+func (c *constructing) cycle(t reflect.Type, provider *providerOpts) (string, bool) {
+	for link := c; link != nil; link = link.parent {
+		if link.provider != provider {
+			continue
+		}
+
+		path := []string{t.String()}
+
+		for ; c != link.parent; c = c.parent {
+			path = append([]string{c.typ.String()}, path...)
+		}
+
+		return strings.Join(path, " -> "), true
+	}
+
+	return "", false
+}
+
+func (b *Van) newSingleton(ctx context.Context, t reflect.Type, provider *providerOpts) (reflect.Value, error) {
+	node := &constructing{
+		typ:      t,
+		provider: provider,
+		parent:   constructingFromContext(ctx),
+	}
+
+	// the singleton might be held by another goroutine that is waiting for the parent
+	if node.parent != nil {
+		if path, ok := b.waits.wait(node.parent, node); ok {
+			return reflect.ValueOf(nil), fmt.Errorf("circular singleton dependency: %s", path)
 		}
+
+		defer b.waits.done(node.parent, node)
 	}
 
-	inst, err := provider.call(args[:numIn])
+	ctx = context.WithValue(ctx, constructingKey{}, node)
+
+	// only one call constructs the singleton, while the others wait for it unless their context is cancelled
+	select {
+	case provider.building <- struct{}{}:
+		defer func() { <-provider.building }()
+	case <-ctx.Done():
+		return reflect.ValueOf(nil), fmt.Errorf("waiting for singleton %s: %w", t.String(), ctx.Err())
+	}
+
+	provider.RLock()
+	instance := provider.instance
+	provider.RUnlock()
+
+	if instance != nil {
+		return reflect.ValueOf(instance), nil
+	}
+
+	inst, err := b.construct(ctx, t, provider)
 	if err != nil {
-		return reflect.ValueOf(nil), fmt.Errorf("failed to resolve dependency %s: %w", t.String(), err)
+		return reflect.ValueOf(nil), err
+	}
+
+	provider.Lock()
+	provider.instance = inst.Interface()
+	provider.Unlock()
+
+	// the provider might have been replaced or removed while the instance was being constructed
+	b.mu.Lock()
+	if provider.group || b.providers[providerKey{typ: t, name: provider.name}] == provider {
+		b.addConcrete(inst.Interface())
 	}
+	b.mu.Unlock()
 
 	return inst, nil
 }
 
-func (b *Van) newSingleton(ctx context.Context, t reflect.Type) (reflect.Value, error) {
-	provider := b.providers[t]
+// Init constructs all singletons upfront, e.g. to fail fast during the app startup rather than on the first
+// command. The context is passed to the singleton providers that take one, so a slow construction, such as
+// establishing a database connection, can be aborted with a deadline. Singletons that fail to construct are
+// constructed again on the next attempt. Init returns the first error it encounters.
+func (b *Van) Init(ctx context.Context) error {
+	b.mu.RLock()
+
+	keys := make([]providerKey, 0, len(b.providers))
+	for k, p := range b.providers {
+		if p.singleton {
+			keys = append(keys, k)
+		}
+	}
 
-	provider.Lock()
-	defer provider.Unlock()
+	b.mu.RUnlock()
 
-	if provider.instance != nil {
-		return reflect.ValueOf(provider.instance), nil
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		if _, err := b.newInstance(ctx, key); err != nil {
+			return fmt.Errorf("failed to initialize %s: %w", key.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// construct resolves the dependencies of the provider and calls it to create a new instance of type t.
+func (b *Van) construct(ctx context.Context, t reflect.Type, provider *providerOpts) (reflect.Value, error) {
+	if b.tracer == nil {
+		return b.constructInstance(ctx, t, provider)
 	}
 
-	providerType := reflect.TypeOf(provider.fn)
+	ctx, end := b.tracer.StartSpan(ctx, "resolve "+t.String())
 
-	var args [maxArgs]reflect.Value
+	inst, err := b.constructInstance(ctx, t, provider)
+	end(err)
 
-	numIn := providerType.NumIn()
+	return inst, err
+}
+
+func (b *Van) constructInstance(ctx context.Context, t reflect.Type, provider *providerOpts) (reflect.Value, error) {
+	fn := provider.fn
 
-	if numIn > len(args) {
-		return reflect.ValueOf(nil), fmt.Errorf("too many dependencies for provider %s", providerType.String())
+	if provider.lazy != nil {
+		var err error
+
+		if fn, err = provider.lazy.provider(t); err != nil {
+			return reflect.ValueOf(nil), err
+		}
 	}
 
+	providerType := reflect.TypeOf(fn)
+
+	var stack [maxArgs]reflect.Value
+
+	numIn := providerType.NumIn()
+	args := argsBuffer(stack[:], numIn)
+
 	if numIn > 0 {
 		err := b.resolve(ctx, nil, providerType, args[:numIn])
 		if err != nil {
@@ -440,20 +2042,225 @@ func (b *Van) newSingleton(ctx context.Context, t reflect.Type) (reflect.Value,
 		}
 	}
 
-	inst, err := provider.call(args[:numIn])
+	timed := b.slowResolveThreshold > 0 || b.hooks.OnResolve != nil
+
+	var start time.Time
+	if timed {
+		start = time.Now()
+	}
+
+	inst, cleanup, err := provider.call(fn, args[:numIn])
+
+	if timed {
+		elapsed := time.Since(start)
+
+		if b.slowResolveThreshold > 0 && elapsed > b.slowResolveThreshold {
+			b.logger.Printf("van: slow dependency resolution: type=%s duration=%s threshold=%s", t.String(), elapsed, b.slowResolveThreshold)
+		}
+
+		if b.hooks.OnResolve != nil {
+			b.hooks.OnResolve(t, elapsed)
+		}
+	}
+
 	if err != nil {
 		return reflect.ValueOf(nil), fmt.Errorf("failed to resolve dependency %s: %w", t.String(), err)
 	}
 
-	provider.instance = inst.Interface()
+	if cleanup != nil {
+		b.cleanupMu.Lock()
+		b.cleanups = append(b.cleanups, cleanup)
+		b.cleanupMu.Unlock()
+	}
 
-	return inst, nil
+	if inst.IsNil() {
+		return reflect.ValueOf(nil), &NilProviderError{Type: t, Name: provider.name}
+	}
+
+	return b.decorate(t, inst)
+}
+
+var (
+	// ErrProviderNotFound is wrapped by the errors about dependencies that have no provider registered.
+	ErrProviderNotFound = errors.New("no providers registered")
+	// ErrHandlerNotFound is wrapped by the errors about commands that have no handler registered.
+	ErrHandlerNotFound = errors.New("no handlers found")
+	// ErrInvalidSignature is matched by the SignatureError about a function of the wrong type passed to the bus.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// NilProviderError is returned when a provider returns a nil instance without an error,
+// instead of injecting the nil dependency.
+type NilProviderError struct {
+	// Type is the type of the dependency.
+	Type reflect.Type
+	// Name is the name of the provider, empty for the default one.
+	Name string
+}
+
+func (e *NilProviderError) Error() string {
+	return fmt.Sprintf("provider for %s returned nil without an error", providerKey{typ: e.Type, name: e.Name}.String())
+}
+
+// validateRegisteredDependency checks the dependency of a function that is being registered.
+// With deferred validation, the check is postponed until Validate is called or the dependency is resolved.
+func (b *Van) validateRegisteredDependency(t reflect.Type) error {
+	if b.deferValidation {
+		return nil
+	}
+
+	return b.validateDependency(t)
+}
+
+// Validate checks that all dependencies of the registered providers, including the group ones, handlers and
+// listeners have providers, and that singleton providers do not depend on providers that take Context or are
+// auto-closed. All failures are reported at once, joined in a stable order.
+// It is primarily meant to be used with WithDeferredValidation after all registrations are done, but it is
+// safe to call in the default mode as well.
+func (b *Van) Validate() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	providers := make([]*providerOpts, 0, len(b.providers))
+	for _, p := range b.providers {
+		providers = append(providers, p)
+	}
+
+	for _, group := range b.groups {
+		providers = append(providers, group...)
+	}
+
+	// all failures are collected and sorted, so that the result does not depend on the order of the maps
+	failures := make(map[string]error)
+	fail := func(err error) {
+		failures[err.Error()] = err
+	}
+
+	for _, p := range providers {
+		providerType := reflect.TypeOf(p.fn)
+
+		for i := 0; i < providerType.NumIn(); i++ {
+			if err := b.validateDependency(providerType.In(i)); err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	// propagate the flags through the dependency graph until nothing changes, since the providers
+	// could have been registered in any order
+	for changed := true; changed; {
+		changed = false
+
+		for _, p := range providers {
+			providerType := reflect.TypeOf(p.fn)
+
+			for i := 0; i < providerType.NumIn(); i++ {
+				dep, ok := b.providers[providerKey{typ: providerType.In(i)}]
+				if !ok {
+					continue
+				}
+
+				if dep.takesContext && !p.takesContext {
+					p.takesContext, changed = true, true
+				}
+
+				if dep.usesAutoClose && !p.usesAutoClose {
+					p.usesAutoClose, changed = true, true
+				}
+			}
+		}
+	}
+
+	for _, p := range providers {
+		switch {
+		case p.singleton && p.takesContext:
+			fail(fmt.Errorf("singleton providers cannot depend on providers that take Context"))
+		case p.singleton && p.usesAutoClose:
+			fail(fmt.Errorf("singleton providers cannot depend on auto-closed providers"))
+		}
+	}
+
+	for cmdType, h := range b.handlers {
+		for _, fn := range h.funcs() {
+			handlerType := reflect.TypeOf(fn)
+
+			for i := handlerDepsStart(handlerType, cmdType); i < handlerType.NumIn(); i++ {
+				if err := b.validateDependency(handlerType.In(i)); err != nil {
+					fail(err)
+				}
+			}
+		}
+	}
+
+	for _, listeners := range b.listeners {
+		for _, listener := range listeners {
+			listenerType := reflect.TypeOf(listener.fn)
+
+			for i := messageIndex(listenerType) + 1; i < listenerType.NumIn(); i++ {
+				if err := b.validateDependency(listenerType.In(i)); err != nil {
+					fail(err)
+				}
+			}
+		}
+	}
+
+	msgs := make([]string, 0, len(failures))
+	for msg := range failures {
+		msgs = append(msgs, msg)
+	}
+
+	sort.Strings(msgs)
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = failures[msg]
+	}
+
+	return errors.Join(errs...)
+}
+
+// provider looks up the provider by its key.
+func (b *Van) provider(key providerKey) (*providerOpts, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	p, ok := b.providers[key]
+
+	return p, ok
+}
+
+// validateDependencyLocked takes the read lock and calls validateDependency.
+func (b *Van) validateDependencyLocked(t reflect.Type) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.validateDependency(t)
 }
 
+// validateDependency checks that there is a provider for the dependency. It must be called with the lock held.
 func (b *Van) validateDependency(t reflect.Type) error {
+	if _, ok := optionalType(t); ok || isGroupType(t) {
+		return nil
+	}
+
 	if t.Kind() == reflect.Struct {
-		for _, field := range reflect.VisibleFields(t) {
-			if err := b.validateDependency(field.Type); err != nil {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			if field.Type.Kind() == reflect.Struct {
+				if err := b.validateDependency(field.Type); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			name, optional := parseTag(field.Tag)
+			if optional {
+				continue
+			}
+
+			if err := b.validateNamedDependency(field.Type, name); err != nil {
 				return err
 			}
 		}
@@ -461,9 +2268,27 @@ func (b *Van) validateDependency(t reflect.Type) error {
 		return nil
 	}
 
-	if _, ok := b.providers[t]; ok || t == typeVan || t == typeContext {
+	return b.validateNamedDependency(t, "")
+}
+
+func (b *Van) validateNamedDependency(t reflect.Type, name string) error {
+	key := providerKey{typ: t, name: name}
+
+	if _, ok := b.providers[key]; ok {
+		return nil
+	}
+
+	if name == "" && t == typeVan && b.strictBus {
+		return errStrictBus
+	}
+
+	if name == "" && b.isConcreteType(t) {
+		return nil
+	}
+
+	if name == "" && (t == typeVan || t == typePublisher || t == typeDispatcher || t == typeScope || t == typeContext) {
 		return nil
 	}
 
-	return fmt.Errorf("no providers registered for type %s", t.String())
+	return fmt.Errorf("%w for type %s", ErrProviderNotFound, key.String())
 }
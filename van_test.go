@@ -3,8 +3,12 @@ package van
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func panicsWithError(t *testing.T, wantErr string, f func()) {
@@ -126,7 +130,7 @@ func TestProvideFails(t *testing.T) {
 		},
 		"first return value not an interface": {
 			provider: func() (int, error) { return 1, nil },
-			wantErr:  "provider's first return value must be an interface, got int",
+			wantErr:  "provider's first return value must be an interface, struct pointer, slice or map, got int",
 		},
 		"second return value not an error": {
 			provider: func() (GetIntService, int) { return nil, 1 },
@@ -136,7 +140,7 @@ func TestProvideFails(t *testing.T) {
 			provider: func(int) (GetIntService, error) {
 				return &GetIntServiceImpl{}, nil
 			},
-			wantErr: "argument 0 must be an interface, struct or *van.Van, got int",
+			wantErr: "argument 0 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"unknown interface": {
 			provider: func(s SetIntService) (GetIntService, error) {
@@ -150,6 +154,12 @@ func TestProvideFails(t *testing.T) {
 			},
 			wantErr: "provider function has a dependency of the same type",
 		},
+		"scope dependency": {
+			provider: func(s *Scope) (GetIntService, error) {
+				return &GetIntServiceImpl{}, nil
+			},
+			wantErr: "argument 0 is *van.Scope, which is only available in command handlers",
+		},
 	}
 
 	for name, tt := range tests {
@@ -204,7 +214,7 @@ func TestProvideSingletonFails(t *testing.T) {
 		},
 		"first return value not an interface": {
 			provider: func() (int, error) { return 1, nil },
-			wantErr:  "provider's first return value must be an interface, got int",
+			wantErr:  "provider's first return value must be an interface, struct pointer, slice or map, got int",
 		},
 		"second return value not an error": {
 			provider: func() (GetIntService, int) { return nil, 1 },
@@ -214,7 +224,7 @@ func TestProvideSingletonFails(t *testing.T) {
 			provider: func(int) (GetIntService, error) {
 				return &GetIntServiceImpl{}, nil
 			},
-			wantErr: "argument 0 must be an interface, struct or *van.Van, got int",
+			wantErr: "argument 0 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"unknown interface": {
 			provider: func(s SetIntService) (GetIntService, error) {
@@ -228,12 +238,6 @@ func TestProvideSingletonFails(t *testing.T) {
 			},
 			wantErr: "provider function has a dependency of the same type",
 		},
-		"context as a dependency": {
-			provider: func(ctx context.Context) (SetIntService, error) {
-				return &SetIntSevriceImpl{}, nil
-			},
-			wantErr: "singleton providers cannot use Context as a dependency",
-		},
 	}
 
 	for name, tt := range tests {
@@ -288,31 +292,31 @@ func TestHandleFails(t *testing.T) {
 			wantErr: "handler must be a function, got int",
 		},
 		"less than two args": {
-			cmd:     struct{}{},
+			cmd:     Command{},
 			handler: func() error { return nil },
-			wantErr: "handler must have at least 2 arguments, got 0",
+			wantErr: "handler must take the command, got no arguments",
 		},
 		"second arg is not a pointer": {
-			cmd:     struct{}{},
+			cmd:     Command{},
 			handler: func(context.Context, int) error { return nil },
 			wantErr: "handler's second argument must be a struct pointer, got int",
 		},
 		"second arg is not a struct pointer": {
-			cmd:     struct{}{},
+			cmd:     Command{},
 			handler: func(context.Context, *int) error { return nil },
 			wantErr: "handler's second argument must be a struct pointer, got *int",
 		},
 		"no return values": {
 			cmd:     struct{}{},
 			handler: func(ctx context.Context, msg *struct{}) {},
-			wantErr: "handler must have one return value, got 0",
+			wantErr: "handler must have one or two return values, got 0",
 		},
-		"multiple return values": {
+		"too many return values": {
 			cmd: struct{}{},
-			handler: func(ctx context.Context, msg *struct{}) (error, error) {
-				return nil, nil
+			handler: func(ctx context.Context, msg *struct{}) (int, int, error) {
+				return 0, 0, nil
 			},
-			wantErr: "handler must have one return value, got 2",
+			wantErr: "handler must have one or two return values, got 3",
 		},
 		"return type not an error": {
 			cmd: struct{}{},
@@ -329,8 +333,8 @@ func TestHandleFails(t *testing.T) {
 			wantErr: "no providers registered for type van.SetIntService",
 		},
 		"command type mismatch": {
-			cmd: struct{}{},
-			handler: func(ctx context.Context, cmd *Command) error {
+			cmd: Command{},
+			handler: func(ctx context.Context, cmd *Event) error {
 				return nil
 			},
 			wantErr: "command type mismatch",
@@ -631,11 +635,11 @@ func TestSubscribeFails(t *testing.T) {
 		},
 		"not enough arguments": {
 			handler: func() {},
-			wantErr: "handler must have at least 2 arguments, got 0",
+			wantErr: "handler must take the event, got no arguments",
 		},
 		"first argument not a context": {
-			handler: func(ctx struct{}, event Event) {},
-			wantErr: "handler's first argument must be context.Context, got struct {}",
+			handler: func(ctx int, event Event) {},
+			wantErr: "handler's first argument must be context.Context or a struct, got int",
 		},
 		"second argument not a struct": {
 			handler: func(ctx context.Context, event int) {},
@@ -643,15 +647,19 @@ func TestSubscribeFails(t *testing.T) {
 		},
 		"dependency is not an interface": {
 			handler: func(ctx context.Context, event Event, dep int) {},
-			wantErr: "argument 2 must be an interface, struct or *van.Van, got int",
+			wantErr: "argument 2 must be an interface, struct, struct pointer, slice or map, got int",
 		},
 		"unknown provider": {
 			handler: func(ctx context.Context, event Event, dep UnknownService) {},
 			wantErr: "no providers registered for type van.UnknownService",
 		},
-		"has return values": {
-			handler: func(ctx context.Context, event Event) error { return nil },
-			wantErr: "event handler should not have any return values",
+		"scope dependency": {
+			handler: func(ctx context.Context, event Event, scope *Scope) {},
+			wantErr: "argument 2 is *van.Scope, which is only available in command handlers",
+		},
+		"has non-error return value": {
+			handler: func(ctx context.Context, event Event) int { return 0 },
+			wantErr: "event handler's return type must be error, got int",
 		},
 	}
 
@@ -688,6 +696,64 @@ func TestPublish_SingleListener(t *testing.T) {
 	}
 }
 
+func TestPublish_PointerEvent(t *testing.T) {
+	var received []Event
+
+	bus := New()
+
+	bus.Subscribe(&Event{}, func(ctx context.Context, event Event) {
+		received = append(received, event)
+	})
+
+	if err := bus.Publish(&Event{Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Wait()
+
+	if err := bus.PublishSync(context.Background(), &Event{Value: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 2 || received[0].Value != 1 || received[1].Value != 2 {
+		t.Fatalf("unexpected events: %v", received)
+	}
+}
+
+func TestPublishFails(t *testing.T) {
+	tests := map[string]struct {
+		event   interface{}
+		wantErr string
+	}{
+		"not a struct": {
+			event:   1,
+			wantErr: "event must be a struct value, unlike commands that are passed by pointer, got int",
+		},
+		"nil": {
+			event:   nil,
+			wantErr: "event must be a struct value, unlike commands that are passed by pointer, got <nil>",
+		},
+		"nil pointer": {
+			event:   (*Event)(nil),
+			wantErr: "event must not be a nil pointer, got *van.Event",
+		},
+	}
+
+	bus := New()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := bus.Publish(tt.event); err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+
+			if err := bus.PublishSync(context.Background(), tt.event); err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestPublish_MultipleListeners(t *testing.T) {
 	var listenerACalled, listenerBCalled int
 
@@ -910,3 +976,2003 @@ func TestExec_Fails(t *testing.T) {
 		})
 	}
 }
+
+func TestInvoke_Scope(t *testing.T) {
+	var listenerCalled int
+
+	bus := New()
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		listenerCalled++
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, scope *Scope) error {
+		return scope.Publish(Event{})
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if listenerCalled != 1 {
+		t.Fatalf("listenerCalled != 1, got %d", listenerCalled)
+	}
+}
+
+func TestInvoke_ScopeListenerFails(t *testing.T) {
+	wantErr := errors.New("provider error")
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return nil, wantErr
+	})
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event, s GetIntService) {})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, scope *Scope) error {
+		return scope.Publish(Event{})
+	})
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+}
+
+func TestInvoke_ScopeContext(t *testing.T) {
+	type ctxKey struct{}
+
+	var (
+		listenerCalled int
+		gotValue       interface{}
+	)
+
+	bus := New(WithDedup(time.Minute, func(event interface{}) string {
+		return "event"
+	}))
+
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		listenerCalled++
+		gotValue = ctx.Value(ctxKey{})
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, scope *Scope) error {
+		if err := scope.Publish(Event{}); err != nil {
+			return err
+		}
+
+		return scope.Publish(Event{})
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	if err := bus.Invoke(ctx, &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if listenerCalled != 1 {
+		t.Fatalf("listenerCalled != 1, got %d", listenerCalled)
+	}
+
+	if gotValue != "value" {
+		t.Fatalf("got %v, want the context of the handler", gotValue)
+	}
+}
+
+func TestExec_ScopeFails(t *testing.T) {
+	bus := New()
+
+	err := bus.Exec(context.Background(), func(scope *Scope) error {
+		return nil
+	})
+
+	wantErr := "argument 0 is *van.Scope, which is only available in command handlers"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestHandleFails_ScopeField(t *testing.T) {
+	bus := New()
+
+	type deps struct {
+		Scope *Scope
+	}
+
+	wantErr := "error in dependency struct argument 2: field Scope cannot be *van.Scope, which is only allowed as a command handler argument"
+
+	panicsWithError(t, wantErr, func() {
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command, d deps) error {
+			return nil
+		})
+	})
+}
+
+func TestResolveFails_UnhandledKind(t *testing.T) {
+	bus := New()
+
+	// the function bypasses signature validation, which would normally reject it
+	fnType := reflect.TypeOf(func(context.Context, int) error { return nil })
+	args := make([]reflect.Value, fnType.NumIn())
+
+	err := bus.resolve(context.Background(), nil, fnType, args)
+
+	wantErr := "unresolvable argument 1 of kind int"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestInvoke_Idempotent(t *testing.T) {
+	var handlerExecuted int
+
+	now := time.Unix(0, 0)
+	bus := New(WithClock(func() time.Time { return now }))
+
+	key := func(cmd interface{}) string {
+		return fmt.Sprint(cmd.(*Command).Result)
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		handlerExecuted++
+		return nil
+	}, Idempotent(WithKey(key), time.Minute))
+
+	ctx := context.Background()
+
+	invoke := func(cmd *Command) {
+		if err := bus.Invoke(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invoke(&Command{Result: 1})
+	invoke(&Command{Result: 1})
+
+	if handlerExecuted != 1 {
+		t.Fatalf("handlerExecuted != 1, got %d", handlerExecuted)
+	}
+
+	invoke(&Command{Result: 2})
+
+	if handlerExecuted != 2 {
+		t.Fatalf("handlerExecuted != 2, got %d", handlerExecuted)
+	}
+
+	now = now.Add(time.Minute)
+	invoke(&Command{Result: 1})
+
+	if handlerExecuted != 3 {
+		t.Fatalf("handlerExecuted != 3, got %d", handlerExecuted)
+	}
+}
+
+func TestInvoke_IdempotentHandlerError(t *testing.T) {
+	var handlerExecuted int
+
+	bus := New()
+
+	key := func(cmd interface{}) string {
+		return "key"
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		handlerExecuted++
+		return errors.New("handler error")
+	}, Idempotent(WithKey(key), time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if err := bus.Invoke(context.Background(), &Command{}); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	if handlerExecuted != 2 {
+		t.Fatalf("handlerExecuted != 2, got %d", handlerExecuted)
+	}
+}
+
+func TestInject(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	presetService := &SetIntSevriceImpl{}
+
+	target := &struct {
+		Get      GetIntService
+		Set      SetIntService
+		Ignored  GetIntService `van:"-"`
+		Value    int
+		internal GetIntService
+	}{
+		Set: presetService,
+	}
+
+	if err := bus.Inject(context.Background(), target); err != nil {
+		t.Fatal(err)
+	}
+
+	if target.Get == nil {
+		t.Fatal("expected Get to be injected")
+	}
+
+	if target.Set != presetService {
+		t.Fatal("expected Set to be left untouched")
+	}
+
+	if target.Ignored != nil || target.internal != nil {
+		t.Fatal("expected ignored fields to be left untouched")
+	}
+}
+
+func TestInjectFails(t *testing.T) {
+	tests := map[string]struct {
+		target  interface{}
+		wantErr string
+	}{
+		"not a pointer": {
+			target:  struct{}{},
+			wantErr: "target must be a non-nil pointer to a struct",
+		},
+		"nil pointer": {
+			target:  (*struct{})(nil),
+			wantErr: "target must be a non-nil pointer to a struct",
+		},
+		"unknown interface": {
+			target:  &struct{ S UnknownService }{},
+			wantErr: "failed to inject field S: no providers registered for type van.UnknownService",
+		},
+	}
+
+	bus := New()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := bus.Inject(context.Background(), tt.target)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvokeAsync(t *testing.T) {
+	type ctxKey struct{}
+
+	release := make(chan struct{})
+	wantErr := errors.New("failed")
+
+	bus := New()
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		return constIntService(ctx.Value(ctxKey{}).(int)), nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		<-release
+
+		cmd.Result = get.Get()
+
+		return wantErr
+	})
+
+	cmd := &Command{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, 42)
+
+	done := bus.InvokeAsync(ctx, cmd)
+
+	select {
+	case <-done:
+		t.Fatal("expected InvokeAsync not to wait for the handler")
+	default:
+	}
+
+	close(release)
+
+	if err := <-done; !errors.Is(err, wantErr) {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+
+	if cmd.Result != 42 {
+		t.Fatalf("expected the dependency to be resolved with the given context, got %d", cmd.Result)
+	}
+
+	// the result is buffered, so nobody has to receive it
+	bus.InvokeAsync(ctx, &struct{}{})
+	bus.Wait()
+}
+
+func TestInvokeCopy(t *testing.T) {
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		cmd.Result++
+		return nil
+	})
+
+	cmd := &Command{Result: 1}
+
+	wg := sync.WaitGroup{}
+	wg.Add(5)
+
+	for i := 0; i < 5; i++ {
+		go func() {
+			defer wg.Done()
+
+			if err := bus.InvokeCopy(context.Background(), cmd); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected the original command to be unchanged, got %d", cmd.Result)
+	}
+}
+
+func TestInvokeCopyFails(t *testing.T) {
+	bus := New()
+
+	err := bus.InvokeCopy(context.Background(), Command{})
+	if err == nil || err.Error() != "cmd must be a pointer to a struct" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestInvokeValue(t *testing.T) {
+	var handled int
+
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		handled = cmd.Result
+		cmd.Result++
+
+		return nil
+	})
+
+	cmd := Command{Result: 1}
+
+	if err := bus.InvokeValue(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 1 {
+		t.Fatalf("expected the handler to receive the command, got %d", handled)
+	}
+
+	if cmd.Result != 1 {
+		t.Fatalf("expected the original command to be unchanged, got %d", cmd.Result)
+	}
+
+	for _, cmd := range []interface{}{&Command{}, nil} {
+		err := bus.InvokeValue(context.Background(), cmd)
+		if err == nil || err.Error() != "cmd must be a struct" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestInvoke_IdempotentHasher(t *testing.T) {
+	type hashCommand struct {
+		ID    int
+		Extra string
+	}
+
+	var handlerExecuted int
+
+	bus := New()
+
+	// a deliberately bad hash to check that collisions are not deduplicated
+	hash := func(cmd interface{}) uint64 {
+		return uint64(cmd.(*hashCommand).ID % 2)
+	}
+
+	bus.Handle(hashCommand{}, func(ctx context.Context, cmd *hashCommand) error {
+		handlerExecuted++
+		return nil
+	}, Idempotent(WithHasher(hash), time.Minute))
+
+	ctx := context.Background()
+
+	for _, cmd := range []*hashCommand{
+		{ID: 1, Extra: "a"},
+		{ID: 1, Extra: "a"}, // duplicate
+		{ID: 3, Extra: "a"}, // same hash, different command
+		{ID: 1, Extra: "b"}, // same hash, different command
+	} {
+		if err := bus.Invoke(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if handlerExecuted != 3 {
+		t.Fatalf("handlerExecuted != 3, got %d", handlerExecuted)
+	}
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func TestWaitContext(t *testing.T) {
+	release := make(chan struct{})
+
+	bus := New()
+	bus.Subscribe(Event{}, func(ctx context.Context, event Event) {
+		<-release
+	})
+
+	if err := bus.Publish(Event{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := bus.WaitContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+
+	if err := bus.WaitContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var order []string
+
+	bus := New()
+
+	bus.ProvideWithCleanup(func() (SetIntService, func(), error) {
+		return &SetIntSevriceImpl{}, func() { order = append(order, "set") }, nil
+	})
+
+	bus.ProvideWithCleanup(func(s SetIntService) (GetIntService, func(), error) {
+		return &GetIntServiceImpl{}, func() { order = append(order, "get") }, nil
+	})
+
+	err := bus.Exec(context.Background(), func(g GetIntService) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"get", "set"}) {
+		t.Fatalf("unexpected cleanup order: %v", order)
+	}
+}
+
+func TestShutdown_NotConstructed(t *testing.T) {
+	var cleanedUp bool
+
+	bus := New()
+	bus.ProvideWithCleanup(func() (SetIntService, func(), error) {
+		return &SetIntSevriceImpl{}, func() { cleanedUp = true }, nil
+	})
+
+	if err := bus.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if cleanedUp {
+		t.Fatal("cleanup of a singleton that was never constructed should not be called")
+	}
+}
+
+func TestShutdown_CleanupPanics(t *testing.T) {
+	var cleanedUp bool
+
+	bus := New()
+
+	bus.ProvideWithCleanup(func() (SetIntService, func(), error) {
+		return &SetIntSevriceImpl{}, func() { cleanedUp = true }, nil
+	})
+
+	bus.ProvideWithCleanup(func(s SetIntService) (GetIntService, func(), error) {
+		return &GetIntServiceImpl{}, func() { panic("boom") }, nil
+	})
+
+	err := bus.Exec(context.Background(), func(g GetIntService) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.Shutdown(context.Background())
+	if err == nil || err.Error() != "cleanup panicked: boom" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cleanedUp {
+		t.Fatal("remaining cleanups should be called after a panic")
+	}
+}
+
+func TestProvideWithCleanupFails(t *testing.T) {
+	tests := map[string]struct {
+		provider ProviderFunc
+		wantErr  string
+	}{
+		"no cleanup": {
+			provider: func() (SetIntService, error) { return nil, nil },
+			wantErr:  "provider must have three return values, got 2",
+		},
+		"cleanup is not a func()": {
+			provider: func() (SetIntService, func() error, error) { return nil, nil, nil },
+			wantErr:  "provider's second return value must be func(), got func() error",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			panicsWithError(t, tt.wantErr, func() {
+				New().ProvideWithCleanup(tt.provider)
+			})
+		})
+	}
+}
+
+type constIntService int
+
+func (s constIntService) Get() int {
+	return int(s)
+}
+
+func TestProvideNamed(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return constIntService(1), nil
+	})
+
+	bus.ProvideNamed("two", func() (GetIntService, error) {
+		return constIntService(2), nil
+	})
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return constIntService(3), nil
+	}, Named("three"))
+
+	type deps struct {
+		Default GetIntService
+		Two     GetIntService `van:"two"`
+		Three   GetIntService `van:"three"`
+	}
+
+	err := bus.Exec(context.Background(), func(d deps, g GetIntService) error {
+		if d.Default.Get() != 1 || g.Get() != 1 {
+			t.Fatalf("expected the default provider, got %d and %d", d.Default.Get(), g.Get())
+		}
+
+		if d.Two.Get() != 2 {
+			t.Fatalf("expected 2, got %d", d.Two.Get())
+		}
+
+		if d.Three.Get() != 3 {
+			t.Fatalf("expected 3, got %d", d.Three.Get())
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvideNamedFails(t *testing.T) {
+	bus := New()
+
+	bus.ProvideNamed("two", func() (GetIntService, error) {
+		return constIntService(2), nil
+	})
+
+	type deps struct {
+		Other GetIntService `van:"other"`
+	}
+
+	err := bus.Exec(context.Background(), func(d deps) error {
+		return nil
+	})
+
+	wantErr := `no providers registered for type van.GetIntService named "other"`
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+
+	err = bus.Exec(context.Background(), func(g GetIntService) error {
+		return nil
+	})
+
+	wantErr = "no providers registered for type van.GetIntService"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("got %v, want %q", err, wantErr)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		return constIntService(1), nil
+	})
+
+	get := func() int {
+		var value int
+
+		err := bus.Exec(context.Background(), func(g GetIntService) error {
+			value = g.Get()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return value
+	}
+
+	if v := get(); v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+
+	bus.Override(func() (GetIntService, error) {
+		return constIntService(2), nil
+	})
+
+	if v := get(); v != 2 {
+		t.Fatalf("expected 2, got %d", v)
+	}
+
+	if !bus.providers[providerKey{typ: typeOf[GetIntService]()}].singleton {
+		t.Fatal("expected the provider to keep its lifetime")
+	}
+
+	bus.RemoveProvider((*GetIntService)(nil))
+
+	err := bus.Exec(context.Background(), func(g GetIntService) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error after removing the provider")
+	}
+}
+
+func TestOverrideFails(t *testing.T) {
+	tests := map[string]struct {
+		setup    func(bus *Van)
+		provider ProviderFunc
+		wantErr  string
+	}{
+		"no provider to replace": {
+			setup:    func(bus *Van) {},
+			provider: func() (GetIntService, error) { return nil, nil },
+			wantErr:  "no providers registered for type van.GetIntService",
+		},
+		"invalid signature": {
+			setup:    func(bus *Van) {},
+			provider: func() GetIntService { return nil },
+			wantErr:  "provider must have two return values, got 1",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bus := New()
+			tt.setup(bus)
+
+			panicsWithError(t, tt.wantErr, func() {
+				bus.Override(tt.provider)
+			})
+		})
+	}
+}
+
+func TestRemoveProvider_StructPointer(t *testing.T) {
+	bus := New()
+
+	type Config struct{}
+
+	bus.Provide(func() (*Config, error) {
+		return &Config{}, nil
+	})
+
+	bus.RemoveProvider((**Config)(nil))
+
+	_, err := Resolve[*Config](context.Background(), bus)
+	if !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrProviderNotFound)
+	}
+}
+
+func TestRemoveProviderFails(t *testing.T) {
+	panicsWithError(t, "expected a pointer to an interface, struct pointer, slice or map, got *van.GetIntServiceImpl", func() {
+		New().RemoveProvider(&GetIntServiceImpl{})
+	})
+}
+
+func TestHandleWithTimeout(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func(ctx context.Context) (GetIntService, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatal("expected the provider to receive the context with the deadline")
+		}
+
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.HandleWithTimeout(Command{}, func(ctx context.Context, cmd *Command, g GetIntService) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, 10*time.Millisecond)
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestHandleWithTimeout_IgnoresContext(t *testing.T) {
+	bus := New()
+
+	bus.HandleWithTimeout(Command{}, func(ctx context.Context, cmd *Command) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}, 10*time.Millisecond)
+
+	err := bus.Invoke(context.Background(), &Command{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if err.Error() != "handler of van.Command timed out after 10ms: context deadline exceeded" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvideValueAs(t *testing.T) {
+	bus := New()
+
+	instance := &GetIntServiceImpl{}
+	bus.ProvideValueAs((*GetIntService)(nil), instance)
+
+	err := bus.Exec(context.Background(), func(g GetIntService) error {
+		if g != instance {
+			t.Fatalf("expected %v, got %v", instance, g)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvideValueAsFails(t *testing.T) {
+	tests := map[string]struct {
+		ifacePtr interface{}
+		instance interface{}
+		wantErr  string
+	}{
+		"not a pointer": {
+			ifacePtr: GetIntService(&GetIntServiceImpl{}),
+			instance: &GetIntServiceImpl{},
+			wantErr:  "expected a pointer to an interface, struct pointer, slice or map, got *van.GetIntServiceImpl",
+		},
+		"not implemented": {
+			ifacePtr: (*SetIntService)(nil),
+			instance: &GetIntServiceImpl{},
+			wantErr:  "*van.GetIntServiceImpl does not implement van.SetIntService",
+		},
+		"nil instance": {
+			ifacePtr: (*SetIntService)(nil),
+			instance: nil,
+			wantErr:  "<nil> does not implement van.SetIntService",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			panicsWithError(t, tt.wantErr, func() {
+				New().ProvideValueAs(tt.ifacePtr, tt.instance)
+			})
+		})
+	}
+}
+
+func TestInvoke_ManyDependencies(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	type G = GetIntService
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command,
+		g1, g2, g3, g4, g5, g6, g7, g8, g9, g10, g11, g12, g13, g14, g15, g16, g17 G) error {
+		for _, g := range []G{g1, g2, g3, g4, g5, g6, g7, g8, g9, g10, g11, g12, g13, g14, g15, g16, g17} {
+			cmd.Result += g.Get()
+		}
+
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 17 {
+		t.Fatalf("expected 17, got %d", cmd.Result)
+	}
+}
+
+func TestProvideScoped(t *testing.T) {
+	var constructed int
+
+	bus := New()
+
+	bus.ProvideScoped(func() (GetIntService, error) {
+		constructed++
+		return constIntService(constructed), nil
+	})
+
+	bus.Provide(func(g GetIntService) (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	var seen []int
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s SetIntService, g GetIntService, deps struct {
+		G GetIntService
+	}) error {
+		if g != deps.G {
+			t.Fatal("expected the same instance within a call")
+		}
+
+		seen = append(seen, g.Get())
+
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if constructed != 2 {
+		t.Fatalf("expected one instance per call, got %d", constructed)
+	}
+
+	if !reflect.DeepEqual(seen, []int{1, 2}) {
+		t.Fatalf("expected different instances for different calls, got %v", seen)
+	}
+}
+
+func TestProvideScopedFails(t *testing.T) {
+	bus := New()
+
+	bus.ProvideScoped(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	panicsWithError(t, "singleton providers cannot depend on providers that take Context", func() {
+		bus.ProvideOnce(func(s SetIntService) (GetIntService, error) {
+			return &GetIntServiceImpl{}, nil
+		})
+	})
+}
+
+func TestVan_ConcurrentRegistration(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		cmd.Result = get.Get()
+		return nil
+	})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			bus.Provide(func() (SetIntService, error) {
+				return &SetIntSevriceImpl{}, nil
+			})
+
+			bus.Subscribe(Event{}, func(ctx context.Context, event Event, set SetIntService) {})
+
+			bus.Use(func(next InvokeFunc) InvokeFunc {
+				return next
+			})
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			cmd := &Command{}
+			if err := bus.Invoke(context.Background(), cmd); err != nil {
+				t.Error(err)
+			}
+
+			if cmd.Result != 1 {
+				t.Errorf("cmd.Result != 1, got %d", cmd.Result)
+			}
+
+			_ = bus.PublishSync(context.Background(), Event{})
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestProvideOnce_CircularDependency(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.ProvideOnce(func(s SetIntService) (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Provide(func(u UnknownService) (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	bus.ProvideOnce(func(g GetIntService) (UnknownService, error) {
+		return struct{}{}, nil
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- bus.Exec(context.Background(), func(g GetIntService) error {
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		wantErr := "circular singleton dependency: van.GetIntService -> van.UnknownService -> van.GetIntService"
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("expected %q, got %v", wantErr, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("singleton construction deadlocked")
+	}
+}
+
+func TestProvideFromContext(t *testing.T) {
+	type ctxKey struct{}
+
+	bus := New()
+	bus.ProvideFromContext((*GetIntService)(nil), ctxKey{})
+
+	instance := &GetIntServiceImpl{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, instance)
+
+	err := bus.Exec(ctx, func(g GetIntService) error {
+		if g != instance {
+			t.Fatalf("expected %v, got %v", instance, g)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.Exec(context.Background(), func(g GetIntService) error {
+		return nil
+	})
+
+	wantErr := "failed to resolve dependency van.GetIntService: no value for key {} in the context"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected %q, got %v", wantErr, err)
+	}
+
+	ctx = context.WithValue(context.Background(), ctxKey{}, 1)
+
+	err = bus.Exec(ctx, func(g GetIntService) error {
+		return nil
+	})
+
+	wantErr = "failed to resolve dependency van.GetIntService: context value int does not implement van.GetIntService"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected %q, got %v", wantErr, err)
+	}
+
+	panicsWithError(t, "singleton providers cannot depend on providers that take Context", func() {
+		bus.ProvideOnce(func(g GetIntService) (SetIntService, error) {
+			return &SetIntSevriceImpl{}, nil
+		})
+	})
+}
+
+func TestProvide_NilInstance(t *testing.T) {
+	bus := New()
+
+	bus.ProvideNamed("named", func() (GetIntService, error) {
+		return nil, nil
+	})
+
+	type deps struct {
+		Get GetIntService `van:"named"`
+	}
+
+	err := bus.Exec(context.Background(), func(d deps) error {
+		t.Fatal("expected the function not to be called")
+		return nil
+	})
+
+	var nilErr *NilProviderError
+	if !errors.As(err, &nilErr) || nilErr.Type != typeOf[GetIntService]() || nilErr.Name != "named" {
+		t.Fatalf("expected a NilProviderError, got %v", err)
+	}
+
+	wantErr := `provider for van.GetIntService named "named" returned nil without an error`
+	if err.Error() != wantErr {
+		t.Fatalf("expected %q, got %q", wantErr, err.Error())
+	}
+}
+
+type getSetIntService struct {
+	value int
+}
+
+func (s *getSetIntService) Get() int  { return s.value }
+func (s *getSetIntService) Set(v int) { s.value = v }
+
+func TestProvideAlso(t *testing.T) {
+	type GetSetIntService interface {
+		GetIntService
+		SetIntService
+	}
+
+	var calls int
+
+	bus := New()
+
+	bus.ProvideAlso(func() (GetSetIntService, error) {
+		calls++
+		return &getSetIntService{value: 1}, nil
+	}, (*GetIntService)(nil), (*SetIntService)(nil))
+
+	err := bus.Exec(context.Background(), func(gs GetSetIntService, g GetIntService, s SetIntService) error {
+		if g.Get() != 1 {
+			t.Fatalf("expected 1, got %d", g.Get())
+		}
+
+		s.Set(2)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected the provider to be called for each dependency, got %d", calls)
+	}
+}
+
+func TestProvideAlsoFails(t *testing.T) {
+	tests := map[string]struct {
+		ifacePtr interface{}
+		wantErr  string
+	}{
+		"not a pointer": {
+			ifacePtr: GetIntService(&GetIntServiceImpl{}),
+			wantErr:  "expected a pointer to an interface, got *van.GetIntServiceImpl",
+		},
+		"not implemented": {
+			ifacePtr: (*SetIntService)(nil),
+			wantErr:  "van.GetIntService does not implement van.SetIntService",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bus := New()
+
+			panicsWithError(t, tt.wantErr, func() {
+				bus.ProvideAlso(func() (GetIntService, error) {
+					return &GetIntServiceImpl{}, nil
+				}, tt.ifacePtr)
+			})
+
+			if len(bus.Providers()) != 0 {
+				t.Fatal("expected nothing to be registered")
+			}
+		})
+	}
+}
+
+type NestedDeps struct {
+	Get GetIntService
+}
+
+func TestExec_NestedDependencyStruct(t *testing.T) {
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Provide(func() (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	type deps struct {
+		NestedDeps
+		Named struct {
+			Set SetIntService
+		}
+	}
+
+	err := bus.Exec(context.Background(), func(d deps) error {
+		if d.Get == nil || d.Named.Set == nil {
+			t.Fatal("expected the nested dependencies to be injected")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.Exec(context.Background(), func(d struct{ N struct{ U UnknownService } }) error {
+		return nil
+	})
+	if err == nil || err.Error() != "no providers registered for type van.UnknownService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTryRegister(t *testing.T) {
+	bus := New()
+
+	err := bus.TryProvide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.TryProvide(func() GetIntService { return nil })
+	if err == nil || err.Error() != "provider must have two return values, got 1" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = bus.TryHandle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bus.TryHandle(Command{}, func(ctx context.Context, cmd *Command, set SetIntService) error {
+		return nil
+	})
+	if err == nil || err.Error() != "no providers registered for type van.SetIntService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unsubscribe, err := bus.TrySubscribe(Event{}, func(ctx context.Context, event Event) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+
+	_, err = bus.TrySubscribe(Event{},
+		func(ctx context.Context, event Event) {},
+		func(ctx context.Context, event Event, set SetIntService) {},
+	)
+	if err == nil || err.Error() != "no providers registered for type van.SetIntService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(bus.Events()) != 0 {
+		t.Fatal("expected none of the listeners to be registered")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	bus := New()
+
+	if err := bus.CheckProvider(func() (GetIntService, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	err := bus.CheckProvider(func(s SetIntService) (GetIntService, error) { return nil, nil })
+	if err == nil || err.Error() != "no providers registered for type van.SetIntService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bus.HasProvider((*GetIntService)(nil)) {
+		t.Fatal("expected the provider not to be registered")
+	}
+
+	err = bus.CheckHandler(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		return nil
+	})
+	if err == nil || err.Error() != "no providers registered for type van.GetIntService" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = bus.CheckHandler(Command{}, func(ctx context.Context, cmd int) error { return nil })
+	if err == nil || err.Error() != "handler's second argument must be a struct pointer, got int" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := bus.CheckHandler(Command{}, func(ctx context.Context, cmd *Command) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if bus.HasHandler(Command{}) {
+		t.Fatal("expected the handler not to be registered")
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error { return nil })
+
+	err = bus.CheckHandler(Command{}, func(ctx context.Context, cmd *Command) error { return nil })
+	if err == nil || err.Error() != "handler already registered for type van.Command" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHandleWithRetry(t *testing.T) {
+	var (
+		attempts  int
+		providers int
+		backoffs  []int
+	)
+
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		providers++
+		return &GetIntServiceImpl{}, nil
+	})
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return time.Millisecond
+		},
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}
+
+	bus.HandleWithRetry(Command{}, func(ctx context.Context, cmd *Command, get GetIntService) error {
+		attempts++
+
+		if cmd.Result > 0 {
+			return errPermanent
+		}
+
+		if attempts < 3 {
+			return errTransient
+		}
+
+		return nil
+	}, policy)
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 3 || providers != 3 {
+		t.Fatalf("expected 3 attempts with fresh dependencies, got %d attempts and %d provider calls", attempts, providers)
+	}
+
+	if len(backoffs) != 2 || backoffs[0] != 1 || backoffs[1] != 2 {
+		t.Fatalf("unexpected backoff calls: %v", backoffs)
+	}
+
+	attempts = 0
+
+	if err := bus.Invoke(context.Background(), &Command{Result: 1}); err != errPermanent {
+		t.Fatalf("expected the permanent error, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("expected the permanent error not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestHandleWithRetry_Cancelled(t *testing.T) {
+	var attempts int
+
+	errTransient := errors.New("transient")
+
+	bus := New()
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			return time.Hour
+		},
+	}
+
+	bus.HandleWithRetry(Command{}, func(ctx context.Context, cmd *Command) error {
+		attempts++
+		return errTransient
+	}, policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := bus.Invoke(ctx, &Command{}); err != errTransient {
+		t.Fatalf("expected the error of the last attempt, got %v", err)
+	}
+
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestHandlePipeline(t *testing.T) {
+	var calls []string
+
+	errUnauthorized := errors.New("unauthorized")
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.HandlePipeline(Command{},
+		func(ctx context.Context, cmd *Command) error {
+			calls = append(calls, "validate")
+			return nil
+		},
+		func(ctx context.Context, cmd *Command) error {
+			calls = append(calls, "authorize")
+
+			if cmd.Result < 0 {
+				return errUnauthorized
+			}
+
+			return nil
+		},
+		func(ctx context.Context, cmd *Command, get GetIntService) error {
+			calls = append(calls, "execute")
+			cmd.Result = get.Get()
+
+			return nil
+		},
+	)
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 1 || strings.Join(calls, ",") != "validate,authorize,execute" {
+		t.Fatalf("unexpected pipeline run: result=%d, calls=%v", cmd.Result, calls)
+	}
+
+	calls = nil
+
+	if err := bus.Invoke(context.Background(), &Command{Result: -1}); err != errUnauthorized {
+		t.Fatalf("expected %v, got %v", errUnauthorized, err)
+	}
+
+	if strings.Join(calls, ",") != "validate,authorize" {
+		t.Fatalf("expected the pipeline to stop at the first error, got %v", calls)
+	}
+}
+
+func TestHandlePipelineFails(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "pipeline must have at least one handler", func() {
+		bus.HandlePipeline(Command{})
+	})
+
+	panicsWithError(t, "no providers registered for type van.SetIntService", func() {
+		bus.HandlePipeline(Command{},
+			func(ctx context.Context, cmd *Command) error { return nil },
+			func(ctx context.Context, cmd *Command, set SetIntService) error { return nil },
+		)
+	})
+
+	if len(bus.Handlers()) != 0 {
+		t.Fatal("expected the pipeline not to be registered")
+	}
+}
+
+func TestProvideOnce_WithContext(t *testing.T) {
+	type ctxKey struct{}
+
+	var got []interface{}
+
+	bus := New()
+
+	bus.ProvideOnce(func(ctx context.Context) (GetIntService, error) {
+		got = append(got, ctx.Value(ctxKey{}))
+		return &GetIntServiceImpl{}, nil
+	})
+
+	// the singleton does not make its dependents context-dependent
+	bus.ProvideOnce(func(get GetIntService) (SetIntService, error) {
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	for i := 1; i <= 2; i++ {
+		ctx := context.WithValue(context.Background(), ctxKey{}, i)
+
+		if _, err := Resolve[SetIntService](ctx, bus); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("expected the context of the first call only, got %v", got)
+	}
+}
+
+func TestInit(t *testing.T) {
+	var constructed []string
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		constructed = append(constructed, "get")
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Provide(func() (SetIntService, error) {
+		constructed = append(constructed, "set")
+		return &SetIntSevriceImpl{}, nil
+	})
+
+	if err := bus.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(constructed, ",") != "get" {
+		t.Fatalf("expected only the singletons to be constructed, got %v", constructed)
+	}
+}
+
+func TestInit_Deadline(t *testing.T) {
+	bus := New()
+
+	bus.ProvideOnce(func(ctx context.Context) (GetIntService, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := bus.Init(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	wantErr := "failed to initialize van.GetIntService: failed to resolve dependency van.GetIntService: context deadline exceeded"
+	if err.Error() != wantErr {
+		t.Fatalf("got %q, want %q", err.Error(), wantErr)
+	}
+}
+
+func TestProvideOnce_WaitCancelled(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+
+	bus := New()
+
+	bus.ProvideOnce(func() (GetIntService, error) {
+		close(started)
+		<-release
+
+		return &GetIntServiceImpl{}, nil
+	})
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := Resolve[GetIntService](context.Background(), bus)
+		done <- err
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := Resolve[GetIntService](ctx, bus); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the waiting call to time out, got %v", err)
+	}
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProvide_StructPointer(t *testing.T) {
+	type Config struct {
+		Value int
+	}
+
+	type Deps struct {
+		Config *Config
+	}
+
+	bus := New()
+
+	bus.ProvideOnce(func() (*Config, error) {
+		return &Config{Value: 42}, nil
+	})
+
+	bus.Provide(func(cfg *Config) (GetIntService, error) {
+		return constIntService(cfg.Value), nil
+	})
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, get GetIntService, deps Deps) error {
+		cmd.Result = get.Get() + deps.Config.Value
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 84 {
+		t.Fatalf("expected 84, got %d", cmd.Result)
+	}
+}
+
+func TestProvide_StructPointerNoProvider(t *testing.T) {
+	type Config struct{}
+
+	bus := New()
+
+	panicsWithError(t, "no providers registered for type *van.Config", func() {
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command, cfg *Config) error {
+			return nil
+		})
+	})
+}
+
+func TestProvideGroup_StructPointer(t *testing.T) {
+	type Config struct{}
+
+	bus := New()
+
+	panicsWithError(t, "group providers must return an interface, got *van.Config", func() {
+		bus.ProvideGroup(func() (*Config, error) { return &Config{}, nil })
+	})
+}
+
+func TestSentinelErrors(t *testing.T) {
+	bus := New(WithDeferredValidation())
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, svc UnknownService) error {
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); !errors.Is(err, ErrProviderNotFound) {
+		t.Errorf("expected ErrProviderNotFound, got %v", err)
+	}
+
+	if err := bus.Validate(); !errors.Is(err, ErrProviderNotFound) {
+		t.Errorf("expected ErrProviderNotFound from Validate, got %v", err)
+	}
+
+	if err := bus.ValidateGraph(); !errors.Is(err, ErrProviderNotFound) {
+		t.Errorf("expected ErrProviderNotFound from ValidateGraph, got %v", err)
+	}
+
+	if err := bus.Invoke(context.Background(), &struct{}{}); !errors.Is(err, ErrHandlerNotFound) {
+		t.Errorf("expected ErrHandlerNotFound, got %v", err)
+	}
+
+	err := bus.TryHandle(Command{}, func(ctx context.Context) error { return nil })
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	if err.Error() != "handler must have at least 2 arguments, got 1" {
+		t.Errorf("unexpected message: %v", err)
+	}
+
+	if err := bus.Exec(context.Background(), 1); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature from Exec, got %v", err)
+	}
+}
+
+func TestHandle_Parameterless(t *testing.T) {
+	type FlushCommand struct{}
+
+	var flushed int
+
+	bus := New()
+
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(FlushCommand{}, func(ctx context.Context, svc GetIntService) error {
+		flushed += svc.Get()
+		return nil
+	})
+
+	bus.Handle(struct{}{}, func(ctx context.Context) error {
+		flushed += 10
+		return nil
+	})
+
+	if err := bus.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Invoke(context.Background(), &FlushCommand{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Invoke(context.Background(), &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if flushed != 11 {
+		t.Fatalf("expected both handlers to be called, got %d", flushed)
+	}
+}
+
+func TestHandle_ParameterlessFails(t *testing.T) {
+	type FlushCommand struct{}
+
+	bus := New()
+
+	panicsWithError(t, "no providers registered for type van.UnknownService", func() {
+		bus.Handle(FlushCommand{}, func(ctx context.Context, svc UnknownService) error {
+			return nil
+		})
+	})
+
+	panicsWithError(t, "no providers registered for type van.UnknownService", func() {
+		bus.Handle(FlushCommand{}, func(svc UnknownService) error {
+			return nil
+		})
+	})
+}
+
+func TestHandle_WithoutContext(t *testing.T) {
+	type FlushCommand struct{}
+
+	bus := New()
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	bus.Handle(Command{}, func(cmd *Command, svc GetIntService) error {
+		cmd.Result = svc.Get()
+		return nil
+	})
+
+	flushed := false
+	bus.Handle(FlushCommand{}, func(svc GetIntService) error {
+		flushed = svc.Get() == 1
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cmd.Result != 1 {
+		t.Errorf("expected result 1, got %d", cmd.Result)
+	}
+
+	if err := bus.Invoke(context.Background(), &FlushCommand{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !flushed {
+		t.Error("parameterless handler has not been called")
+	}
+}
+
+func TestSubscribe_WithoutContext(t *testing.T) {
+	bus := New()
+	bus.Provide(func() (GetIntService, error) {
+		return &GetIntServiceImpl{}, nil
+	})
+
+	var got, gotAll int
+
+	bus.Subscribe(Event{}, func(event Event, svc GetIntService) {
+		got = event.Value + svc.Get()
+	})
+
+	bus.SubscribeAll(func(event interface{}) {
+		gotAll = event.(Event).Value
+	})
+
+	if err := bus.Publish(Event{Value: 41}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus.Wait()
+
+	if got != 42 {
+		t.Errorf("expected 42, got %d", got)
+	}
+
+	if gotAll != 41 {
+		t.Errorf("expected 41, got %d", gotAll)
+	}
+}
+
+type UpdateCommand[T any] struct {
+	Value   T
+	Handled string
+}
+
+func TestHandle_GenericCommand(t *testing.T) {
+	bus := New()
+
+	bus.Handle(UpdateCommand[int]{}, func(ctx context.Context, cmd *UpdateCommand[int]) error {
+		cmd.Handled = "int"
+		return nil
+	})
+
+	bus.Handle(UpdateCommand[string]{}, func(ctx context.Context, cmd *UpdateCommand[string]) error {
+		cmd.Handled = "string"
+		return nil
+	})
+
+	intCmd := &UpdateCommand[int]{Value: 1}
+	if err := bus.Invoke(context.Background(), intCmd); err != nil {
+		t.Fatal(err)
+	}
+
+	stringCmd := &UpdateCommand[string]{Value: "a"}
+	if err := bus.Invoke(context.Background(), stringCmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if intCmd.Handled != "int" || stringCmd.Handled != "string" {
+		t.Fatalf("unexpected handlers called: %q, %q", intCmd.Handled, stringCmd.Handled)
+	}
+
+	panicsWithError(t, "handler already registered for type van.UpdateCommand[int]", func() {
+		bus.Handle(UpdateCommand[int]{}, func(ctx context.Context, cmd *UpdateCommand[int]) error {
+			return nil
+		})
+	})
+
+	err := bus.Invoke(context.Background(), &UpdateCommand[bool]{})
+	if err == nil || err.Error() != "no handlers found for type van.UpdateCommand[bool]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHandle_Duplicate(t *testing.T) {
+	bus := New()
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+		cmd.Result = 1
+		return nil
+	})
+
+	panicsWithError(t, "handler already registered for type van.Command", func() {
+		bus.Handle(Command{}, func(ctx context.Context, cmd *Command) error {
+			return nil
+		})
+	})
+
+	err := bus.TryHandle(Command{}, func(ctx context.Context, cmd *Command) error {
+		return nil
+	})
+	if err == nil || err.Error() != "handler already registered for type van.Command" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bus.ReplaceHandler(Command{}, func(ctx context.Context, cmd *Command) error {
+		cmd.Result = 2
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 2 {
+		t.Fatalf("expected the replaced handler to be called, got %d", cmd.Result)
+	}
+}
+
+func TestReplaceHandler_NotRegistered(t *testing.T) {
+	bus := New()
+
+	panicsWithError(t, "no handlers found for type van.Command", func() {
+		bus.ReplaceHandler(Command{}, func(ctx context.Context, cmd *Command) error {
+			return nil
+		})
+	})
+}
+
+// boundSetIntService stores the value in the underlying service, so that it can be bound to SetIntService.
+type boundSetIntService struct {
+	Target *getSetIntService
+}
+
+func (s *boundSetIntService) Set(v int) { s.Target.Set(v) }
+
+func TestBind(t *testing.T) {
+	target := &getSetIntService{}
+
+	bus := New()
+	bus.Provide(func() (*getSetIntService, error) {
+		return target, nil
+	})
+
+	bus.Bind((*SetIntService)(nil), (*boundSetIntService)(nil))
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, s SetIntService) error {
+		s.Set(42)
+		return nil
+	})
+
+	if err := bus.Invoke(context.Background(), &Command{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if target.value != 42 {
+		t.Fatalf("expected value 42, got %d", target.value)
+	}
+}
+
+func TestBindFails(t *testing.T) {
+	tests := map[string]struct {
+		ifacePtr    interface{}
+		concretePtr interface{}
+		wantErr     string
+	}{
+		"not an interface": {
+			ifacePtr:    (*boundSetIntService)(nil),
+			concretePtr: (*boundSetIntService)(nil),
+			wantErr:     "expected a pointer to an interface, got *van.boundSetIntService",
+		},
+		"not a struct pointer": {
+			ifacePtr:    (*SetIntService)(nil),
+			concretePtr: boundSetIntService{},
+			wantErr:     "expected a pointer to a struct, got van.boundSetIntService",
+		},
+		"not implemented": {
+			ifacePtr:    (*GetIntService)(nil),
+			concretePtr: (*boundSetIntService)(nil),
+			wantErr:     "*van.boundSetIntService does not implement van.GetIntService",
+		},
+		"unexported field": {
+			ifacePtr:    (*GetIntService)(nil),
+			concretePtr: (*getSetIntService)(nil),
+			wantErr:     "error in dependency struct argument 0: field value must be exported",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			panicsWithError(t, tt.wantErr, func() {
+				New().Bind(tt.ifacePtr, tt.concretePtr)
+			})
+		})
+	}
+}
+
+func TestProvideValueAs_SliceAndMap(t *testing.T) {
+	type Hosts []string
+
+	bus := New()
+
+	bus.ProvideValueAs((*Hosts)(nil), Hosts{"a", "b"})
+	bus.ProvideValueAs((*map[string]int)(nil), map[string]int{"a": 1})
+
+	type Deps struct {
+		Ports map[string]int
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, hosts Hosts, deps Deps) error {
+		cmd.Result = len(hosts) + deps.Ports["a"]
+		return nil
+	})
+
+	cmd := &Command{}
+	if err := bus.Invoke(context.Background(), cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Result != 3 {
+		t.Fatalf("expected 3, got %d", cmd.Result)
+	}
+
+	panicsWithError(t, "[]string is not of type van.Hosts", func() {
+		bus.ProvideValueAs((*Hosts)(nil), []string{"c"})
+	})
+}
+
+func TestInvoke_StructDepsSharedDependency(t *testing.T) {
+	type Shared interface {
+		Get() int
+	}
+
+	var constructed int
+
+	bus := New()
+
+	bus.Provide(func() (Shared, error) {
+		constructed++
+		return &getSetIntService{value: constructed}, nil
+	})
+
+	bus.Provide(func(shared Shared) (GetIntService, error) {
+		return constIntService(shared.Get()), nil
+	})
+
+	type Deps struct {
+		Shared Shared
+		Nested struct {
+			Shared Shared
+			Get    GetIntService
+		}
+	}
+
+	bus.Handle(Command{}, func(ctx context.Context, cmd *Command, deps Deps) error {
+		if deps.Shared != deps.Nested.Shared {
+			return errors.New("expected the fields to share the instance")
+		}
+
+		cmd.Result = deps.Nested.Get.Get()
+
+		return nil
+	})
+
+	for i := 1; i <= 2; i++ {
+		cmd := &Command{}
+		if err := bus.Invoke(context.Background(), cmd); err != nil {
+			t.Fatal(err)
+		}
+
+		if constructed != i || cmd.Result != i {
+			t.Fatalf("expected the shared dependency to be constructed once per struct, got %d constructions", constructed)
+		}
+	}
+}